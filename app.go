@@ -7,11 +7,13 @@ import (
     "encoding/base64"
     "encoding/json"
     "fmt"
+    "maps"
     "math"
     "log"
     "os"
     "os/exec"
     "path/filepath"
+    "slices"
     "strconv"
 	"strings" // Added for string manipulation
 	"sync"
@@ -32,16 +34,58 @@ type VideoFile struct {
 	ThumbnailBase64 string  `json:"thumbnailBase64"`
 	HasAudio        bool    `json:"hasAudio"`
 	FPS             float64 `json:"fps"`
+	FrameRate       string  `json:"frameRate"` // exact average rate as a fraction, e.g. "30000/1001"
 	PixelFormat     string  `json:"pixelFormat"`
 	SampleRate      int     `json:"sampleRate"`
 	ChannelLayout   string  `json:"channelLayout"`
+	Rotation        int     `json:"rotation"` // clockwise display rotation: 0, 90, 180 or 270
+	ColorPrimaries  string  `json:"colorPrimaries"`
+	ColorTransfer   string  `json:"colorTransfer"`
+	ColorSpace      string  `json:"colorSpace"` // matrix coefficients
+	ColorRange      string  `json:"colorRange"` // "tv" (limited) or "pc" (full)
+	BitDepth        int     `json:"bitDepth"`
+
+	// HDR10 static metadata in x265 syntax, "" when the stream has none
+	MasteringDisplay string `json:"masteringDisplay"` // G(x,y)B(x,y)R(x,y)WP(x,y)L(max,min)
+	MaxCLL           string `json:"maxCll"`           // MaxCLL,MaxFALL
+
+	// Stream parameters that must match for a stream-copy merge
+	AudioCodec    string `json:"audioCodec"`
+	Profile       string `json:"profile"`
+	Level         int    `json:"level"`
+	TimeBase      string `json:"timeBase"`
+	SAR           string `json:"sar"`
+	FieldOrder    string `json:"fieldOrder"`
+	ExtradataHash string `json:"extradataHash"` // CRC32 of the codec private data (SPS/PPS, ...)
+	Container     string  `json:"container"` // ffprobe format name, e.g. "mov,mp4,m4a,3gp,3g2,mj2"
+	StartTime     float64 `json:"startTime"` // first timestamp; continuous across the parts of a split recording
+	CreationTime  string  `json:"creationTime"` // creation_time tag as RFC 3339, "" if missing
+
+	// Seconds left out at either end of the clip, see trimmedClips
+	TrimStart float64 `json:"trimStart"`
+	TrimEnd   float64 `json:"trimEnd"`
+	cut       bool    // Duration already excludes the trims
 }
 
 // MergePreset defines the settings for the output video.
 type MergePreset struct {
 	Name    string `json:"name"`
-	Format  string `json:"format"`  // e.g., "mp4", "mkv"
-	Quality int    `json:"quality"` // e.g., 22 (CRF value for H.264)
+	Format  string `json:"format"` // container, e.g. "mp4", "mkv"; "copy" prefers stream copy
+	BuiltIn bool   `json:"builtIn"`
+
+	VideoCodec  OutputCodec `json:"videoCodec"` // "" keeps the source codec when possible
+	Quality     int         `json:"quality"`    // unified 0-100 level, see qualityValue
+	RateControl RateControl `json:"rateControl"`
+
+	ResolutionPolicy string  `json:"resolutionPolicy"` // "largest", "first" or "fixed"
+	Width            int     `json:"width"`            // fixed resolution only
+	Height           int     `json:"height"`
+	FPSPolicy        string  `json:"fpsPolicy"` // "fixed", "first" or "highest"
+	FPS              float64 `json:"fps"`       // fixed rate; 0 = 30
+
+	AudioCodec      string `json:"audioCodec"`      // "" picks one for the container
+	AudioChannels   int    `json:"audioChannels"`   // 1, 2, 6 or 8; 0 = stereo
+	AudioSampleRate int    `json:"audioSampleRate"` // Hz; 0 = 48000
 }
 
 // JobStatus represents the current state of a merge job.
@@ -63,130 +107,346 @@ type MergeJob struct {
 	OutputName  string      `json:"outputName"`
 	Status      JobStatus   `json:"status"`
 	Progress    float64     `json:"progress"` // 0.0 to 100.0
+	OutputPath  string      `json:"outputPath"` // set once the job starts
+	Error       string      `json:"error"`
+
+	opts   mergeOptions       // encode settings captured when the job was queued
+	cancel context.CancelFunc // stops the job while it runs; guarded by jobsMu
 }
 
 // App struct
 type App struct {
 	ctx        context.Context
-	cancelFunc context.CancelFunc
+	cancelMu   sync.Mutex
+	cancelFunc context.CancelFunc // cancels the merge started by MergeVideos
 
 	useHW    bool // Whether to use hardware acceleration
-	encAvail map[string]bool
+	encMu     sync.RWMutex
+	encAvail  map[string]bool // usable encoders; replaced whole, never modified, see encoders
+	hwChecked map[string]bool // hardware trial encode results, nil until they finish
+	encGen    int             // bumped on every detection so a stale one can't publish
+	caps     *FFmpegCapabilities
+	bins     BinaryStatus
+	hdrMode  HDRMode     // How HDR sources are normalized
+	codec    OutputCodec // Video codec for re-encoded output
+	quality  int         // Unified 0-100 quality level
+	rc       RateControl // Rate control for re-encoded output
+
+	keyframes KeyframeOptions // GOP and keyframe placement
+	strategy  MergeStrategy   // How clips are merged when stream copy isn't possible
+
+	intermediate IntermediateFormat // How normalized clips are stored before the join
+	settings  Settings        // Persisted preferences; see applySettings
+
+	preset      MergePreset   // Active preset: container, frame size/rate and audio layout
+	userPresets []MergePreset // User-defined presets stored on disk
+
+	projectPath string // .stitch file last opened or saved
+	outputHint  string   // output file suggested by the open project
+	hintClips   []string // paths of the clips outputHint was suggested for
+
+	jobsMu    sync.Mutex
+	jobs      []*MergeJob // merge queue, see jobs.go
+	jobSeq    int
+	activeJob *MergeJob // job the queue is running, nil when idle
+	queueStop bool      // set by StopQueue to end the queue after the current job
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
-}
-
-func detectEncoders() (map[string]bool, error) {
-	cmd := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error", "-encoders")
-	out, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-	s := string(out)
-	have := map[string]bool{
-		"h264_nvenc": strings.Contains(s, "h264_nvenc"),
-		"hevc_nvenc": strings.Contains(s, "hevc_nvenc"),
-		"h264_qsv":   strings.Contains(s, "h264_qsv"),
-		"hevc_qsv":   strings.Contains(s, "hevc_qsv"),
-		"h264_amf":   strings.Contains(s, "h264_amf"),
-		"hevc_amf":   strings.Contains(s, "hevc_amf"),
-		// Nếu cần macOS:
-		// "h264_videotoolbox": strings.Contains(s, "h264_videotoolbox"),
-		// "hevc_videotoolbox": strings.Contains(s, "hevc_videotoolbox"),
-	}
-	return have, nil
+	a := &App{preset: MergePreset{}.withDefaults()}
+	a.applySettings(defaultSettings())
+	return a
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	_, err := exec.LookPath("ffmpeg")
+	a.setEncoders(map[string]bool{})
+	settings, err := loadSettings()
 	if err != nil {
+		log.Printf("[settings] %v; using defaults", err)
+	}
+	a.applySettings(settings)
+	if a.userPresets, err = loadUserPresets(); err != nil {
+		log.Printf("[presets] %v", err)
+	}
+	// Missing tools no longer end the app: it starts degraded and the user
+	// can point it at working binaries from the UI
+	a.bins = resolveBinaries(a.settings.FFmpegPath, a.settings.FFprobePath)
+	if !a.bins.Ready() {
 		runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
-			Type:    runtime.ErrorDialog,
-			Title:   "Error: FFmpeg not found",
-			Message: "FFmpeg is required for this application to function. Please install it and ensure it is in your system's PATH.\n\nFor installation instructions, please visit: https://ffmpeg.org/download.html",
+			Type:    runtime.WarningDialog,
+			Title:   "FFmpeg not found",
+			Message: "FFmpeg and FFprobe are required to merge videos. Install them on your system's PATH, or choose their location in Settings.\n\n" + strings.TrimSpace(a.bins.FFmpegError+"\n"+a.bins.FFprobeError) + "\n\nFor installation instructions, please visit: https://ffmpeg.org/download.html",
 		})
-		os.Exit(1)
+		if a.bins.FFmpegError != "" {
+			return
+		}
 	}
-	// detect once
-	enc, err := detectEncoders()
-	if err == nil {
-		a.encAvail = enc
-	} else {
-		a.encAvail = map[string]bool{}
-		log.Printf("detectEncoders error: %v", err)
+	a.detectFFmpeg()
+	if a.caps != nil && !a.caps.Supported {
+		runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+			Type:    runtime.WarningDialog,
+			Title:   "Unsupported FFmpeg version",
+			Message: fmt.Sprintf("FFmpeg %s is older than the minimum supported version %s. Merges are disabled until FFmpeg is updated.\n\nFor installation instructions, please visit: https://ffmpeg.org/download.html", a.caps.Version, a.caps.MinimumVersion),
+		})
 	}
+	if a.settings.DefaultPreset != "" {
+		if err := a.ApplyPreset(a.settings.DefaultPreset); err != nil {
+			log.Printf("[presets] default preset: %v", err)
+		}
+	}
+}
+
+// detectFFmpeg (re)reads the capabilities and usable encoders of the current
+// ffmpeg. Software encoders are usable right away; the hardware ones are
+// trial-encoded in the background, which can take a while on machines with
+// misbehaving drivers, and added when they pass. The UI is told with a
+// "hardwareEncoders" event.
+func (a *App) detectFFmpeg() {
+	a.caps = nil
+	a.setEncoders(map[string]bool{})
+	caps, err := detectCapabilities()
+	if err != nil {
+		log.Printf("detectCapabilities error: %v", err)
+		return
+	}
+	a.caps = caps
+	sw := softwareEncoders(caps)
+	gen := a.setEncoders(sw)
+	go func() {
+		hw := verifyHardwareEncoders(caps)
+		have := maps.Clone(sw)
+		maps.Copy(have, hw)
+		a.encMu.Lock()
+		if a.encGen != gen {
+			// ffmpeg was changed meanwhile; its own detection is running
+			a.encMu.Unlock()
+			return
+		}
+		a.encAvail, a.hwChecked = have, hw
+		a.encMu.Unlock()
+		runtime.EventsEmit(a.ctx, "hardwareEncoders", a.GetHardwareEncoders())
+	}()
+}
+
+// setEncoders publishes a new set of usable encoders, with hardware
+// detection pending, and returns its generation.
+func (a *App) setEncoders(have map[string]bool) int {
+	a.encMu.Lock()
+	defer a.encMu.Unlock()
+	a.encGen++
+	a.encAvail, a.hwChecked = have, nil
+	return a.encGen
+}
+
+// encoders returns the usable encoders. The map must not be modified.
+func (a *App) encoders() map[string]bool {
+	a.encMu.RLock()
+	defer a.encMu.RUnlock()
+	return a.encAvail
+}
+
+// GetBinaryStatus returns the ffmpeg/ffprobe paths in use and any problem with them.
+func (a *App) GetBinaryStatus() BinaryStatus {
+	return a.bins
+}
+
+// SetFFmpegPaths points the app at specific ffmpeg and ffprobe binaries.
+// An empty path falls back to the environment, bundled copies and PATH.
+// The binaries are validated first: a working pair is made current,
+// capabilities are re-detected and the choice is remembered for the next
+// launch. Otherwise the binaries in use are kept and nothing is saved; the
+// returned status shows what was tried.
+func (a *App) SetFFmpegPaths(ffmpeg, ffprobe string) (BinaryStatus, error) {
+	bins := resolveBinaries(ffmpeg, ffprobe)
+	if !bins.Ready() {
+		setBinaries(a.bins.FFmpegPath, a.bins.FFprobePath)
+		return bins, fmt.Errorf("%s", strings.TrimSpace(bins.FFmpegError+"\n"+bins.FFprobeError))
+	}
+	a.bins = bins
+	a.settings.FFmpegPath, a.settings.FFprobePath = ffmpeg, ffprobe
+	a.persistSettings()
+	a.detectFFmpeg()
+	return a.bins, nil
+}
+
+// BrowseForBinary opens a file dialog to locate the ffmpeg or ffprobe executable.
+func (a *App) BrowseForBinary(tool string) (string, error) {
+	if tool != "ffmpeg" && tool != "ffprobe" {
+		return "", fmt.Errorf("unknown tool %q", tool)
+	}
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: fmt.Sprintf("Locate %s", tool),
+	})
+}
+
+// GetFFmpegDiagnostics returns the parsed ffmpeg version, build configuration,
+// codecs, filters and hardware encoder checks.
+func (a *App) GetFFmpegDiagnostics() (*FFmpegCapabilities, error) {
+	if a.caps == nil {
+		return nil, fmt.Errorf("ffmpeg capabilities could not be detected")
+	}
+	caps := *a.caps
+	a.encMu.RLock()
+	caps.HardwareEncoders = a.hwChecked
+	a.encMu.RUnlock()
+	return &caps, nil
 }
 
 // gọi từ UI khi người dùng bật/tắt toggle
 func (a *App) SetUseHardwareEncoder(use bool) {
 	a.useHW = use
+	a.persistSettings()
+}
+
+// SetHDRMode selects how HDR clips are normalized: "tonemap" or "keep".
+func (a *App) SetHDRMode(mode string) error {
+	switch HDRMode(mode) {
+	case HDRModeTonemap, HDRModeKeep:
+		a.hdrMode = HDRMode(mode)
+		a.persistSettings()
+		return nil
+	}
+	return fmt.Errorf("unknown HDR mode %q", mode)
 }
 
 // UI có thể gọi để biết có GPU encoder nào khả dụng không & tên nào
 func (a *App) GetHardwareEncoders() []string {
 	names := []string{}
-	for k, ok := range a.encAvail {
-		if ok {
+	for k, ok := range a.encoders() {
+		if ok && isHardwareEncoder(k) {
 			names = append(names, k)
 		}
 	}
 	return names
 }
 
-type EncArgs struct {
-	Codec []string
-	Name  string // tên encoder dùng thực tế (để hiển thị nếu muốn)
+// SetOutputCodec selects the video codec used when re-encoding:
+// "h264", "hevc", "av1", "vp9", or "" to keep the source codec when possible.
+func (a *App) SetOutputCodec(codec string) error {
+	switch OutputCodec(codec) {
+	case CodecAuto, CodecH264, CodecHEVC, CodecAV1, CodecVP9:
+		a.codec = OutputCodec(codec)
+		a.persistSettings()
+		return nil
+	}
+	return fmt.Errorf("unknown output codec %q", codec)
 }
 
-func buildVideoEncoderArgs(useHW bool, have map[string]bool) EncArgs {
-	if useHW {
-		switch {
-		case have["h264_nvenc"]:
-			return EncArgs{
-				Name:  "h264_nvenc",
-				Codec: []string{"-c:v", "h264_nvenc", "-preset", "p4", "-rc", "vbr_hq", "-cq", "23", "-b:v", "0", "-pix_fmt", "yuv420p"},
-			}
-		case have["h264_qsv"]:
-			return EncArgs{
-				Name:  "h264_qsv",
-				Codec: []string{"-c:v", "h264_qsv", "-preset", "medium", "-rc", "icq", "-global_quality", "23", "-pix_fmt", "yuv420p"},
-			}
-		case have["h264_amf"]:
-			return EncArgs{
-				Name:  "h264_amf",
-				Codec: []string{"-c:v", "h264_amf", "-quality", "quality", "-rc", "vbr", "-qvbr_quality_level", "23", "-pix_fmt", "yuv420p"},
+// SetQuality sets the unified quality level (0 = smallest file, 100 = best).
+// It is translated per encoder so toggling hardware encoding keeps the look.
+func (a *App) SetQuality(level int) error {
+	if level < 0 || level > 100 {
+		return fmt.Errorf("quality must be between 0 and 100, got %d", level)
+	}
+	a.quality = level
+	a.persistSettings()
+	return nil
+}
+
+// SetRateControl selects quality-based, average bitrate, constrained VBR or
+// CBR encoding. Bitrates are in kbit/s.
+func (a *App) SetRateControl(rc RateControl) error {
+	if rc.Mode == "" {
+		rc.Mode = RateQuality
+	}
+	if err := rc.validate(); err != nil {
+		return err
+	}
+	a.rc = rc
+	a.persistSettings()
+	return nil
+}
+
+// SetKeyframeOptions sets the keyframe interval, scene-cut and closed-GOP
+// behaviour, and whether keyframes are forced at clip joins.
+func (a *App) SetKeyframeOptions(k KeyframeOptions) error {
+	if err := k.validate(); err != nil {
+		return err
+	}
+	a.keyframes = k
+	a.persistSettings()
+	return nil
+}
+
+// GetQualityTiers returns the named quality levels for the UI.
+func (a *App) GetQualityTiers() map[string]int {
+	return qualityTiers
+}
+
+// GetOutputCodecs returns the codecs that have at least one usable encoder.
+func (a *App) GetOutputCodecs() []string {
+	codecs := []string{}
+	for _, c := range []OutputCodec{CodecH264, CodecHEVC, CodecAV1, CodecVP9} {
+		for _, name := range append(swEncoders[c], hwEncoders[c]...) {
+			if a.encoders()[name] {
+				codecs = append(codecs, string(c))
+				break
 			}
 		}
-		// không có encoder HW khả dụng → rơi xuống CPU
-	}
-	return EncArgs{
-		Name:  "libx264",
-		Codec: []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-pix_fmt", "yuv420p"},
 	}
+	return codecs
 }
 
 // FFProbeStream defines the structure for a stream in ffprobe output
 type FFProbeStream struct {
-	CodecType     string `json:"codec_type"`
-	CodecName     string `json:"codec_name"`
-	Width         int    `json:"width"`
-	Height        int    `json:"height"`
-	AvgFrameRate  string `json:"avg_frame_rate"`
-	PixFmt        string `json:"pix_fmt"`
-	SampleRate    string `json:"sample_rate"`
-	ChannelLayout string `json:"channel_layout"`
+	CodecType     string            `json:"codec_type"`
+	CodecName     string            `json:"codec_name"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	AvgFrameRate  string            `json:"avg_frame_rate"`
+	PixFmt        string            `json:"pix_fmt"`
+	SampleRate    string            `json:"sample_rate"`
+	ChannelLayout string            `json:"channel_layout"`
+	Tags          map[string]string `json:"tags"`
+	SideDataList  []FFProbeSideData `json:"side_data_list"`
+
+	ColorPrimaries   string `json:"color_primaries"`
+	ColorTransfer    string `json:"color_transfer"`
+	ColorSpace       string `json:"color_space"`
+	ColorRange       string `json:"color_range"`
+	BitsPerRawSample string `json:"bits_per_raw_sample"`
+
+	Profile           string `json:"profile"`
+	Level             int    `json:"level"`
+	TimeBase          string `json:"time_base"`
+	SampleAspectRatio string `json:"sample_aspect_ratio"`
+	FieldOrder        string `json:"field_order"`
+	ExtradataHash     string `json:"extradata_hash"` // needs -show_data_hash
+}
+
+// FFProbeSideData defines a side data entry attached to a stream (e.g. the display matrix)
+type FFProbeSideData struct {
+	SideDataType string  `json:"side_data_type"`
+	Rotation     float64 `json:"rotation"`
+
+	// "Mastering display metadata": chromaticities and luminance as rationals
+	RedX         string `json:"red_x"`
+	RedY         string `json:"red_y"`
+	GreenX       string `json:"green_x"`
+	GreenY       string `json:"green_y"`
+	BlueX        string `json:"blue_x"`
+	BlueY        string `json:"blue_y"`
+	WhitePointX  string `json:"white_point_x"`
+	WhitePointY  string `json:"white_point_y"`
+	MinLuminance string `json:"min_luminance"`
+	MaxLuminance string `json:"max_luminance"`
+
+	// "Content light level metadata" in cd/m²
+	MaxContent int `json:"max_content"`
+	MaxAverage int `json:"max_average"`
 }
 
 // FFProbeFormat defines the structure for the format section in ffprobe output
 type FFProbeFormat struct {
-	Duration string `json:"duration"`
-	Size     string `json:"size"`
+	FormatName string            `json:"format_name"`
+	StartTime  string            `json:"start_time"`
+	Duration   string            `json:"duration"`
+	Size       string            `json:"size"`
+	Tags       map[string]string `json:"tags"`
 }
 
 // FFProbeResult defines the overall structure of the ffprobe JSON output
@@ -208,15 +468,60 @@ func parseFrameRate(rate string) float64 {
 	return v
 }
 
+// probeRotation returns the clockwise display rotation of a video stream,
+// snapped to 0, 90, 180 or 270. Older muxers store it as a "rotate" tag,
+// newer ffprobe builds only expose it through the display matrix side data,
+// whose angle is counter-clockwise.
+func probeRotation(s FFProbeStream) int {
+	deg := 0.0
+	if r, ok := s.Tags["rotate"]; ok {
+		deg, _ = strconv.ParseFloat(r, 64)
+	} else {
+		for _, sd := range s.SideDataList {
+			if sd.SideDataType == "Display Matrix" {
+				deg = -sd.Rotation
+				break
+			}
+		}
+	}
+	rot := int(math.Round(deg/90)) * 90 % 360
+	if rot < 0 {
+		rot += 360
+	}
+	return rot
+}
+
+// probeCreationTime returns the container's creation_time, falling back to
+// the video stream's, normalized to RFC 3339. Cameras without a clock write
+// the MP4 epoch (1904) or Unix epoch, which count as missing.
+func probeCreationTime(f FFProbeFormat, s FFProbeStream) string {
+	for _, tag := range []string{f.Tags["creation_time"], s.Tags["creation_time"]} {
+		t, err := time.Parse(time.RFC3339Nano, tag)
+		if err == nil && t.Year() > 1970 {
+			return t.Format(time.RFC3339Nano)
+		}
+	}
+	return ""
+}
+
+// displaySize returns the dimensions a player shows after applying rotation.
+func displaySize(width, height, rotation int) (int, int) {
+	if rotation == 90 || rotation == 270 {
+		return height, width
+	}
+	return width, height
+}
+
 // SelectVideos opens a file dialog and returns a list of video files with basic info.
 // Detailed metadata is fetched separately.
 func (a *App) SelectVideos() ([]VideoFile, error) {
 	filePaths, err := runtime.OpenMultipleFilesDialog(a.ctx, runtime.OpenDialogOptions{
-		Title: "Select Video Files",
+		Title:            "Select Video Files",
+		DefaultDirectory: a.settings.LastInputDir,
 		Filters: []runtime.FileFilter{
 			{
-				DisplayName: "Video Files (*.mp4, *.mkv, *.mov, *.avi, *.webm)",
-				Pattern:     "*.mp4;*.mkv;*.mov;*.avi;*.webm",
+				DisplayName: "Video Files (*.mp4, *.mkv, *.mov, *.avi, *.webm, *.mts, *.m2ts, *.ts, *.vob)",
+				Pattern:     "*.mp4;*.mkv;*.mov;*.avi;*.webm;*.mts;*.m2ts;*.ts;*.vob",
 			},
 		},
 	})
@@ -227,6 +532,8 @@ func (a *App) SelectVideos() ([]VideoFile, error) {
 	if filePaths == nil {
 		return []VideoFile{}, nil
 	}
+	a.settings.LastInputDir = filepath.Dir(filePaths[0])
+	a.persistSettings()
 
 	var videoFiles []VideoFile
 	for _, path := range filePaths {
@@ -241,7 +548,29 @@ func (a *App) SelectVideos() ([]VideoFile, error) {
 
 // GetVideoMetadata fetches detailed information for a single video file.
 func (a *App) GetVideoMetadata(path string) (VideoFile, error) {
-	cmd := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", path)
+	videoFile, err := a.probeVideo(path)
+	if err != nil {
+		return videoFile, err
+	}
+
+	// Generate thumbnail
+	thumbnail, err := a.GenerateThumbnail(path)
+	if err != nil {
+		log.Printf("Error generating thumbnail for %s: %v", path, err)
+		videoFile.ThumbnailBase64 = "" // Continue without thumbnail
+	} else {
+		videoFile.ThumbnailBase64 = thumbnail
+	}
+
+	return videoFile, nil
+}
+
+// probeVideo reads a video file's metadata with ffprobe, without a thumbnail.
+func (a *App) probeVideo(path string) (VideoFile, error) {
+	if a.bins.FFprobeError != "" {
+		return VideoFile{}, fmt.Errorf("%s", a.bins.FFprobeError)
+	}
+	cmd := exec.Command(ffprobeBin(), "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", "-show_data_hash", "CRC32", path)
 	out, err := cmd.Output()
 	if err != nil {
 		log.Printf("Error running ffprobe for %s: %v", path, err)
@@ -274,40 +603,58 @@ func (a *App) GetVideoMetadata(path string) (VideoFile, error) {
 
 	duration, _ := strconv.ParseFloat(ffprobeData.Format.Duration, 64)
 	size, _ := strconv.ParseInt(ffprobeData.Format.Size, 10, 64)
+	startTime, _ := strconv.ParseFloat(ffprobeData.Format.StartTime, 64)
 
 	fps := parseFrameRate(videoStream.AvgFrameRate)
 	sampleRate, _ := strconv.Atoi(audioStream.SampleRate)
 
+	// Report what the viewer sees: a portrait phone clip stored as 1920x1080
+	// with a 90° display matrix is a 1080x1920 video.
+	rotation := probeRotation(videoStream)
+	width, height := displaySize(videoStream.Width, videoStream.Height, rotation)
+
 	videoFile := VideoFile{
 		Path:          path,
 		FileName:      filepath.Base(path),
 		Size:          size,
 		Duration:      duration,
-		Resolution:    fmt.Sprintf("%dx%d", videoStream.Width, videoStream.Height),
+		Resolution:    fmt.Sprintf("%dx%d", width, height),
 		Codec:         videoStream.CodecName,
 		HasAudio:      hasAudio,
 		FPS:           fps,
+		FrameRate:     videoStream.AvgFrameRate,
 		PixelFormat:   videoStream.PixFmt,
 		SampleRate:    sampleRate,
 		ChannelLayout: audioStream.ChannelLayout,
+		Rotation:      rotation,
+
+		ColorPrimaries: videoStream.ColorPrimaries,
+		ColorTransfer:  videoStream.ColorTransfer,
+		ColorSpace:     videoStream.ColorSpace,
+		ColorRange:     videoStream.ColorRange,
+		BitDepth:       probeBitDepth(videoStream),
+
+		MasteringDisplay: probeMasteringDisplay(videoStream),
+		MaxCLL:           probeMaxCLL(videoStream),
+
+		AudioCodec:    audioStream.CodecName,
+		Profile:       videoStream.Profile,
+		Level:         videoStream.Level,
+		TimeBase:      videoStream.TimeBase,
+		SAR:           videoStream.SampleAspectRatio,
+		FieldOrder:    videoStream.FieldOrder,
+		ExtradataHash: videoStream.ExtradataHash,
+		Container:     ffprobeData.Format.FormatName,
+		StartTime:     startTime,
+		CreationTime:  probeCreationTime(ffprobeData.Format, videoStream),
 	}
-
-	// Generate thumbnail
-	thumbnail, err := a.GenerateThumbnail(path)
-	if err != nil {
-		log.Printf("Error generating thumbnail for %s: %v", path, err)
-		videoFile.ThumbnailBase64 = "" // Continue without thumbnail
-	} else {
-		videoFile.ThumbnailBase64 = thumbnail
-	}
-
 	return videoFile, nil
 }
 
 // GenerateThumbnail generates a base64 encoded thumbnail for a given video path.
 func (a *App) GenerateThumbnail(videoPath string) (string, error) {
 	// Use -ss before -i for fast seeking. Output as mjpeg for correct data URI.
-	cmd := exec.Command("ffmpeg",
+	cmd := exec.Command(ffmpegBin(),
 		"-ss", "1",
 		"-i", videoPath,
 		"-frames:v", "1",
@@ -340,25 +687,29 @@ func escapeFFConcatPath(p string) string {
     return s
 }
 
-// GetPresets returns a list of predefined merge presets.
-func (a *App) GetPresets() []MergePreset {
-	return []MergePreset{
-		{Name: "Fast Copy (Same Codec/Res)", Format: "copy", Quality: 0},
-		{Name: "MP4 (H.264) - High Quality", Format: "mp4", Quality: 18},
-		{Name: "MP4 (H.264) - Medium Quality", Format: "mp4", Quality: 23},
-		{Name: "WebM (VP9) - Medium Quality", Format: "webm", Quality: 28},
-	}
-}
-
-// CancelMerge cancels the ongoing video merge operation.
+// CancelMerge cancels the ongoing video merge operation: the one started
+// with MergeVideos, or the queued job that is running.
 func (a *App) CancelMerge() {
-	if a.cancelFunc != nil {
-		a.cancelFunc()
-		a.cancelFunc = nil                          // Clear the cancel function after use
+	a.cancelMu.Lock()
+	cancel := a.cancelFunc
+	a.cancelFunc = nil
+	a.cancelMu.Unlock()
+	if cancel == nil {
+		cancel = a.cancelActiveJob()
+	}
+	if cancel != nil {
+		cancel()
 		runtime.EventsEmit(a.ctx, "mergeCancelled") // Emit an event to the frontend
 	}
 }
 
+// setCancel makes cancel the one CancelMerge calls; nil clears it.
+func (a *App) setCancel(cancel context.CancelFunc) {
+	a.cancelMu.Lock()
+	defer a.cancelMu.Unlock()
+	a.cancelFunc = cancel
+}
+
 // viết list concat cho ffmpeg
 func writeConcatList(paths []string) (string, error) {
 	f, err := os.CreateTemp("", "ffmpeg-list-*.txt")
@@ -377,20 +728,21 @@ func writeConcatList(paths []string) (string, error) {
 }
 
 // thử concat -c copy (fast merge). Trả về nil nếu thành công.
-func tryFastMerge(ctx context.Context, inputPaths []string, output string) error {
-	listFile, err := writeConcatList(inputPaths)
+func tryFastMerge(ctx context.Context, vs []VideoFile, output string, videoCodec string) error {
+	listFile, err := writeClipConcatList(vs)
 	if err != nil {
 		return err
 	}
 	defer os.Remove(listFile)
 
 	// -xerror: coi warning nghiêm trọng là lỗi để fail sớm
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	cmd := exec.CommandContext(ctx, ffmpegBin(),
 		"-y", "-hide_banner", "-loglevel", "error", "-xerror",
 		"-f", "concat", "-safe", "0", "-i", listFile,
 		"-c", "copy",
-		output,
 	)
+	cmd.Args = append(cmd.Args, codecTagArgs(videoCodec, output)...)
+	cmd.Args = append(cmd.Args, output)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("fast merge failed: %v\nffmpeg: %s", err, string(out))
@@ -398,40 +750,6 @@ func tryFastMerge(ctx context.Context, inputPaths []string, output string) error
 	return nil
 }
 
-func looksFastMergeable(vs []VideoFile) bool {
-    if len(vs) == 0 {
-        return false
-    }
-    // Fast merge heuristics (approximate, to avoid false negatives from float rounding)
-    base := vs[0]
-    for _, v := range vs[1:] {
-        if v.Codec != base.Codec {
-            return false
-        }
-        if v.Resolution != base.Resolution {
-            return false
-        }
-        if v.HasAudio != base.HasAudio {
-            return false
-        }
-        // Allow small FPS rounding differences (e.g., 29.97 vs 29.9701)
-        if math.Abs(v.FPS-base.FPS) > 0.05 {
-            return false
-        }
-        // Pixel format is generally consistent for compressed streams; keep strict
-        if v.PixelFormat != base.PixelFormat {
-            return false
-        }
-        // Only check audio params if audio is present
-        if v.HasAudio {
-            if v.SampleRate != base.SampleRate || v.ChannelLayout != base.ChannelLayout {
-                return false
-            }
-        }
-    }
-    return true
-}
-
 func audioMismatch(vs []VideoFile) (has, no bool) {
 	for _, v := range vs {
 		if v.HasAudio {
@@ -448,11 +766,25 @@ func (a *App) MergeVideos(videoFiles []VideoFile) (string, error) {
 	if len(videoFiles) < 2 {
 		return "", fmt.Errorf("at least two videos are required to merge")
 	}
+	if a.queueRunning() {
+		return "", fmt.Errorf("the merge queue is running; stop it first")
+	}
+
+	// Check the ffmpeg version and HDR handling before asking for an output
+	// location so a bad setup or mixed HDR/SDR selection fails fast
+	if err := a.caps.check(jobRequirements{}); err != nil {
+		return "", err
+	}
+	opts := a.mergeOptions()
+	if _, err := planHDR(opts.hdrMode, videoFiles); err != nil {
+		return "", err
+	}
 
 	// 1) Hỏi nơi lưu trước: dùng chung cho fast + fallback
 	outputFile, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
-		Title:           "Save Merged Video As...",
-		DefaultFilename: fmt.Sprintf("merged-video-%s.mp4", time.Now().Format("20060102-150405")),
+		Title:            "Save Merged Video As...",
+		DefaultDirectory: a.outputDir(videoFiles),
+		DefaultFilename:  a.outputName(videoFiles, a.outputExtension()),
 	})
 	if err != nil {
 		return "", err
@@ -460,140 +792,312 @@ func (a *App) MergeVideos(videoFiles []VideoFile) (string, error) {
 	if outputFile == "" {
 		return "", fmt.Errorf("save operation cancelled")
 	}
+	a.settings.LastOutputDir = filepath.Dir(outputFile)
+	a.persistSettings()
 
-	// 2) Thử fast merge nếu “có vẻ” hợp lệ
-	inputPaths := make([]string, len(videoFiles))
-	for i, v := range videoFiles {
-		inputPaths[i] = v.Path
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.setCancel(cancel)
+	defer func() { a.setCancel(nil); cancel() }()
+	return a.mergeTo(ctx, videoFiles, outputFile, opts)
+}
+
+// mergeTo merges the clips into outputFile with the given settings, trying
+// stream copy first and re-encoding with the selected strategy otherwise.
+// Cancelling ctx stops the merge.
+func (a *App) mergeTo(ctx context.Context, videoFiles []VideoFile, outputFile string, o mergeOptions) (string, error) {
+	hdr, err := planHDR(o.hdrMode, videoFiles)
+	if err != nil {
+		return "", err
+	}
+
+	// Camcorder spans, DVD title sets and split TS recordings are one stream
+	// cut into files; join each set at the byte level before anything else
+	if sets := detectSplitRecordings(videoFiles); len(sets) > 0 {
+		joined, cleanup, err := a.joinSplitRecordings(ctx, videoFiles, sets)
+		defer cleanup()
+		if err != nil {
+			return "", err
+		}
+		videoFiles = joined
+		if hdr, err = planHDR(o.hdrMode, videoFiles); err != nil {
+			return "", err
+		}
 	}
 
-	if looksFastMergeable(videoFiles) {
+	// Black frames and dead air at the clip edges would pile up at the joins
+	if o.trimBlack || o.trimSilence {
+		trims, err := a.findEdgeTrims(ctx, videoFiles, o.trimBlack, o.trimSilence)
+		if err != nil {
+			return "", err
+		}
+		videoFiles = applyEdgeTrims(videoFiles, trims)
+	}
+
+	// Repeated footage at the joins is cut from the end of the earlier clip
+	if o.removeOverlaps {
+		overlaps, err := a.findOverlaps(ctx, videoFiles)
+		if err != nil {
+			return "", err
+		}
+		videoFiles = applyOverlaps(videoFiles, overlaps)
+	}
+	if videoFiles, err = trimmedClips(videoFiles); err != nil {
+		return "", err
+	}
+	// Stream copy can't start a clip between keyframes
+	copyOK := !headTrimmed(videoFiles)
+	if !copyOK {
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": "Clip starts are trimmed, so the clips are re-encoded",
+		})
+	}
+
+	// 2) Thử fast merge nếu “có vẻ” hợp lệ
+	// Stream copy keeps the source codec and bitrate, so only try it when
+	// neither was explicitly asked for
+	codecOK := o.codec == CodecAuto || string(o.codec) == videoFiles[0].Codec
+	reasons := copyIncompatibilities(videoFiles)
+	// Differences that only come from the wrappers are fixed by rewrapping
+	streamReasons := streamIncompatibilities(videoFiles, reasons)
+	if len(streamReasons) > 0 {
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": "Clips can't be stream-copied: " + summarizeIncompatibilities(streamReasons, 5),
+		})
+	}
+	// The copy keeps the source streams, so the container must hold those
+	containerOK := true
+	if err := checkCopyContainer(videoFiles, outputFile); err != nil {
+		containerOK = false
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": "Clips can't be stream-copied: " + err.Error(),
+		})
+	}
+	if copyOK && codecOK && containerOK && o.rc.Mode == RateQuality && o.preset.allowsCopy(videoFiles) && len(streamReasons) == 0 {
+		if len(reasons) == 0 && sameContainer(videoFiles) {
     runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
         "message": "Trying fast merge (stream copy)...",
     })
-		ctx, cancel := context.WithCancel(a.ctx)
-		a.cancelFunc = cancel
-		defer func() { cancel(); a.cancelFunc = nil }()
-
-		if err := tryFastMerge(ctx, inputPaths, outputFile); err == nil {
-			return fmt.Sprintf("Successfully merged videos to %s (fast merge)", outputFile), nil
-		} else {
+			if err := tryFastMerge(ctx, videoFiles, outputFile, videoFiles[0].Codec); err == nil {
+				return fmt.Sprintf("Successfully merged videos to %s (fast merge)", outputFile), nil
+			} else {
             log.Printf("[fast-merge] %v", err)
             runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
-                "message": "Fast merge failed, falling back to normalization...",
+                "message": "Fast merge failed, rewrapping clips before joining...",
             })
+			}
+		} else {
+			runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+				"message": "Clips differ only in their containers, rewrapping them before joining...",
+			})
+		}
+
+		err := a.remuxMerge(ctx, videoFiles, outputFile)
+		if err == nil {
+			return fmt.Sprintf("Successfully merged videos to %s (stream copy, rewrapped)", outputFile), nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		log.Printf("[remux] %v", err)
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": "Rewrapping failed, falling back to re-encoding...",
+		})
+	}
+
+	// Conform to majority: only re-encode the clips that stand out
+	if o.strategy == StrategyConform && copyOK && codecOK && o.rc.Mode == RateQuality && len(reasons) > 0 {
+		plan, err := a.planConform(videoFiles, reasons, outputFile, o)
+		if err == nil && !o.preset.allowsCopy([]VideoFile{plan.ref}) {
+			err = fmt.Errorf("the preset asks for a different resolution or frame rate")
+		}
+		if err == nil {
+			err = a.caps.check(plan.requirements(videoFiles))
 		}
+		if err == nil {
+			var result string
+			if result, err = a.conformMerge(ctx, videoFiles, plan, outputFile); err == nil || ctx.Err() != nil {
+				return result, err
+			}
+			log.Printf("[conform] %v", err)
+		}
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("Can't conform to the majority (%v), re-encoding all clips...", err),
+		})
 	}
 
-	// --- Universal Normalization Workflow ---
-    runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
-        "message": "Starting normalization process...",
-    })
+	target, err := a.planTarget(videoFiles, hdr, outputFile, o)
+	if err != nil {
+		return "", err
+	}
+	if err := a.caps.check(target.requirements(videoFiles, o.rc.Mode == RateTargetSize || o.strategy == StrategyTimeline)); err != nil {
+		return "", err
+	}
 
-	// Determine the highest resolution to use as the target
-	highestWidth := 0
-	highestHeight := 0
-	for _, video := range videoFiles {
-		var w, h int
-		fmt.Sscanf(video.Resolution, "%dx%d", &w, &h)
-		if w > highestWidth {
-			highestWidth = w
-			highestHeight = h
+	// Parallel normalization cancels the other clips when one fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// A size budget is shared by the whole timeline, so it can't be met by
+	// normalizing clips independently
+	if o.rc.Mode == RateTargetSize {
+		return a.encodeTimelineToSize(ctx, videoFiles, target, outputFile, o.rc.TargetSizeMB)
+	}
+
+	// A single filter graph avoids intermediate files and lets filters see
+	// the whole timeline
+	if o.strategy == StrategyTimeline {
+		err = a.encodeTimeline(ctx, videoFiles, target, outputFile)
+	} else {
+		err = a.normalizeAndConcat(ctx, cancel, videoFiles, target, outputFile, o.intermediate)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf("Successfully merged videos to %s", outputFile)
+	if o.rc.Mode != RateQuality {
+		report, ok, err := checkOutputBitrate(ctx, outputFile, o.rc)
+		if err != nil {
+			log.Printf("[bitrate-check] %v", err)
+		} else {
+			runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+				"message": report,
+			})
+			if !ok {
+				result += ". Warning: " + report
+			}
 		}
 	}
+	return result, nil
+}
 
-	hasAud, noAud := audioMismatch(videoFiles)
-	needAudioNormalize := hasAud && noAud
+// normalizeAndConcat re-encodes every clip to the target format in parallel,
+// writing intermediate files, and joins them by stream copy.
+func (a *App) normalizeAndConcat(ctx context.Context, cancel context.CancelFunc, videoFiles []VideoFile, target normTarget, outputFile string, intermediate IntermediateFormat) error {
+	// --- Universal Normalization Workflow ---
+    runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+        "message": "Starting normalization process...",
+    })
 
 	// Create a temporary directory for the normalized files
-	tempDir, err := os.MkdirTemp("", "stitcher-normalized-*")
+	tempDir, err := os.MkdirTemp(a.tempDir(), "stitcher-normalized-*")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp dir for normalization: %w", err)
+		return fmt.Errorf("failed to create temp dir for normalization: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
 
-	ctx, cancel := context.WithCancel(a.ctx)
-	a.cancelFunc = cancel
-	defer func() { cancel(); a.cancelFunc = nil }()
-
-	enc := buildVideoEncoderArgs(a.useHW, a.encAvail)
-	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
-		"message": fmt.Sprintf("Using encoder: %s", enc.Name),
-	})
+	// Intermediates go into a container that can hold the target codecs,
+	// whatever the source was wrapped in
+	nearLossless := intermediate == IntermediateNearLossless
+	ext := intermediate.extension(target.codec)
+	firstEnc := target.enc
+	audioArgs := target.audioArgs()
+	if nearLossless {
+		if err := a.caps.check(jobRequirements{encoders: []string{"libx264", "pcm_s16le"}}); err != nil {
+			return err
+		}
+		firstEnc = nearLosslessEncoder(target.hdr.keep)
+		audioArgs = target.pcmAudioArgs()
+	}
 
 	processedFilePaths := make([]string, len(videoFiles))
 	var wg sync.WaitGroup
 	errCh := make(chan error, 1)
 	var completed int32
 	total := len(videoFiles)
+	// Limit how many ffmpeg processes run at once
+	slots := make(chan struct{}, a.workerCount())
 
 	for i, video := range videoFiles {
 		wg.Add(1)
 		i, video := i, video
 		go func() {
 			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return
+			}
             runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
                 "message": fmt.Sprintf("Normalizing %s...", video.FileName),
             })
-			outputFileName := filepath.Join(tempDir, fmt.Sprintf("normalized-%d-%s", i, filepath.Base(video.Path)))
+			outputFileName := filepath.Join(tempDir, fmt.Sprintf("normalized-%d.%s", i, ext))
 
 			// 1) Filter video (scale + pad + fps + SAR)
-			vf := fmt.Sprintf(
-				"scale=%d:%d:force_original_aspect_ratio=decrease,setsar=1,format=yuv420p,"+
-					"pad=%d:%d:(ow-iw)/2:(oh-ih)/2,fps=30",
-				highestWidth, highestHeight, highestWidth, highestHeight)
+			vf := target.videoFilter(i, video)
 
 			// 2) BẮT BUỘC: đưa tất cả -i (input) TRƯỚC khi -map
-			args := []string{
-				"-y", "-hide_banner", "-loglevel", "error",
-				"-i", video.Path, // input 0: file gốc
-			}
+			args := []string{"-y", "-hide_banner", "-loglevel", "error"}
+			args = append(args, video.inputArgs()...) // input 0: file gốc
 
 			// Nếu file này không có audio và đang cần đồng bộ audio -> thêm anullsrc làm input 1
-			synthSilence := needAudioNormalize && !video.HasAudio
+			synthSilence := target.mixedAudio && !video.HasAudio
 			if synthSilence {
 				args = append(args,
-					"-f", "lavfi", "-t", "999999", "-i", "anullsrc=channel_layout=stereo:sample_rate=48000", // input 1
+					"-f", "lavfi", "-t", "999999", "-i", fmt.Sprintf("anullsrc=channel_layout=%s:sample_rate=%d", target.channelLayout(), target.sampleRate), // input 1
 				)
 			}
 
-			// 3) Áp filter + chọn encoder video (GPU/CPU) từ enc.Codec
+			// 3) Áp filter; encoder video (GPU/CPU) được thêm ở bước 5
 			args = append(args, "-vf", vf)
-			args = append(args, enc.Codec...)
 
 			// 4) Map stream & audio để mọi file có cùng layout
 			//    - map video chính
 			//    - bỏ phụ đề/data/metadata/chapters để không lệch số lượng stream
 			args = append(args, "-map", "0:v:0", "-sn", "-dn", "-map_metadata", "-1", "-map_chapters", "-1")
+			// Rotation was baked into the pixels by auto-rotate; make sure no stale
+			// rotate tag is carried over so the clip isn't rotated a second time
+			args = append(args, "-metadata:s:v:0", "rotate=0")
 
-			if needAudioNormalize {
+			if target.mixedAudio {
 				if video.HasAudio {
-					// Có audio -> chuẩn hóa AAC 48k stereo
-					args = append(args, "-map", "0:a:0", "-c:a", "aac", "-ar", "48000", "-ac", "2")
+					// Có audio -> chuẩn hóa theo codec/sample rate/kênh của preset
+					args = append(args, "-map", "0:a:0")
+					args = append(args, audioArgs...)
 				} else {
 					// Không audio -> lấy audio im lặng từ input 1
-					args = append(args, "-map", "1:a:0", "-c:a", "aac", "-ar", "48000", "-ac", "2", "-shortest")
+					args = append(args, "-map", "1:a:0")
+					args = append(args, audioArgs...)
+					args = append(args, "-shortest")
 				}
 			} else {
 				// Tất cả cùng có hoặc cùng không có audio
 				if video.HasAudio {
-					args = append(args, "-map", "0:a:0", "-c:a", "aac", "-ar", "48000", "-ac", "2")
+					args = append(args, "-map", "0:a:0")
+					args = append(args, audioArgs...)
 				} else {
 					args = append(args, "-an")
 				}
 			}
 
-			// 5) Output đích
-			args = append(args, outputFileName)
-
-			// 6) Chạy FFmpeg
-			cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-			var stderr bytes.Buffer
-			cmd.Stderr = &stderr
+			// 5) Encoder + output đích, 6) Chạy FFmpeg
+			run := func(enc EncArgs) (string, error) {
+				full := append(slices.Clone(args), enc.Codec...)
+				full = append(full, target.colorArgs(enc.Name)...)
+				full = append(coalesceParams(full), outputFileName)
+				cmd := exec.CommandContext(ctx, ffmpegBin(), full...)
+				var stderr bytes.Buffer
+				cmd.Stderr = &stderr
+				err := cmd.Run()
+				return stderr.String(), err
+			}
 
-			if err := cmd.Run(); err != nil {
+			stderr, err := run(firstEnc)
+			if err != nil && ctx.Err() == nil && isHardwareEncoder(firstEnc.Name) {
+				// A hardware encoder that passed the trial encode can still fail on a
+				// particular clip (size limits, driver hiccups); redo it on the CPU
+				cpu := target.cpuEncoder(a.encoders())
+				msg := fmt.Sprintf("%s failed on %s, retrying with %s", target.enc.Name, video.FileName, cpu.Name)
+				log.Printf("[hw-fallback] %s: %v\n%s", msg, err, stderr)
+				runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+					"message": msg,
+				})
+				stderr, err = run(cpu)
+			}
+			if err != nil {
 				select {
-				case errCh <- fmt.Errorf("failed to normalize %s: %v\nffmpeg:\n%s", video.FileName, err, stderr.String()):
+				case errCh <- fmt.Errorf("failed to normalize %s: %v\nffmpeg:\n%s", video.FileName, err, stderr):
 				default:
 				}
 				return
@@ -618,9 +1122,12 @@ func (a *App) MergeVideos(videoFiles []VideoFile) (string, error) {
 	case err := <-errCh:
 		cancel()
 		<-doneCh
-		return "", err
+		return err
 	case <-doneCh:
 	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("merge cancelled by user")
+	}
 
     runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
         "message": "Normalization complete. Starting final merge...",
@@ -631,27 +1138,39 @@ func (a *App) MergeVideos(videoFiles []VideoFile) (string, error) {
 	// Create a temporary file to list the inputs for ffmpeg
 	tempFile, err := os.CreateTemp("", "ffmpeg-list-*.txt")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file for ffmpeg: %w", err)
+		return fmt.Errorf("failed to create temp file for ffmpeg: %w", err)
 	}
 	defer os.Remove(tempFile.Name())
 
 	for _, path := range processedFilePaths {
 		line := fmt.Sprintf("file '%s'\n", escapeFFConcatPath(path))
 		if _, err := tempFile.WriteString(line); err != nil {
-			return "", fmt.Errorf("failed to write to temp file: %w", err)
+			return fmt.Errorf("failed to write to temp file: %w", err)
 		}
 	}
 	tempFile.Close()
 
-	// Calculate total duration for progress reporting
-	var totalDuration float64
-	for _, video := range videoFiles {
-		totalDuration += video.Duration
+	if nearLossless {
+		return a.encodeJoined(ctx, tempFile.Name(), videoFiles, target, outputFile)
 	}
 
 	// All files are now standardized, so a fast stream copy is safe and reliable.
+	args := []string{"-y", "-f", "concat", "-safe", "0", "-i", tempFile.Name(), "-c", "copy"}
+	args = append(args, joinCopyArgs(ext, target, outputFile)...)
+	args = append(args, codecTagArgs(string(target.codec), outputFile)...)
+	args = append(args, outputFile)
+	if err := a.runFFmpegProgress(ctx, args, totalDuration(videoFiles), "Merging...", 0, 100); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runFFmpegProgress runs ffmpeg and reports its progress over totalDuration
+// seconds as mergeProgress events, scaled into the [from, to] percent range
+// so multi-step jobs can share one progress bar.
+func (a *App) runFFmpegProgress(ctx context.Context, args []string, totalDuration float64, message string, from, to float64) error {
 	// Use "-nostats -progress -" to pipe structured progress to stdout.
-	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-f", "concat", "-safe", "0", "-i", tempFile.Name(), "-c", "copy", "-nostats", "-progress", "-", outputFile)
+	cmd := exec.CommandContext(ctx, ffmpegBin(), append([]string{"-nostats", "-progress", "-"}, args...)...)
 
 	// Stderr will be used to capture actual errors, since stdout is for progress
 	var stderr bytes.Buffer
@@ -659,11 +1178,11 @@ func (a *App) MergeVideos(videoFiles []VideoFile) (string, error) {
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return "", fmt.Errorf("failed to create stdout pipe for progress: %w", err)
+		return fmt.Errorf("failed to create stdout pipe for progress: %w", err)
 	}
 
 	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start ffmpeg command: %w", err)
+		return fmt.Errorf("failed to start ffmpeg command: %w", err)
 	}
 
 	// Goroutine to read and parse ffmpeg's structured progress from stdout
@@ -690,21 +1209,22 @@ func (a *App) MergeVideos(videoFiles []VideoFile) (string, error) {
 					if percentage > 100 {
 						percentage = 100
 					}
-                runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
-                    "percentage": percentage,
-                    "current":    progressSeconds,
-                    "total":      totalDuration,
-                    "message":    "Merging...",
-                })
+					a.setJobProgress(from + percentage*(to-from)/100)
+					runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+						"percentage": from + percentage*(to-from)/100,
+						"current":    progressSeconds,
+						"total":      totalDuration,
+						"message":    message,
+					})
 				}
-			} else if key == "progress" && value == "end" {
+			} else if key == "progress" && value == "end" && to >= 100 {
 				// Ensure the progress bar hits 100% on completion
-                runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
-                    "percentage": 100.0,
-                    "current":    totalDuration,
-                    "total":      totalDuration,
-                    "message":    "Merge complete",
-                })
+				runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+					"percentage": 100.0,
+					"current":    totalDuration,
+					"total":      totalDuration,
+					"message":    "Merge complete",
+				})
 			}
 		}
 		if err := scanner.Err(); err != nil {
@@ -715,11 +1235,10 @@ func (a *App) MergeVideos(videoFiles []VideoFile) (string, error) {
 	err = cmd.Wait()
 	if err != nil {
 		if ctx.Err() == context.Canceled {
-			return "", fmt.Errorf("merge cancelled by user")
+			return fmt.Errorf("merge cancelled by user")
 		}
 		// Include ffmpeg's stderr in the error message for better debugging
-		return "", fmt.Errorf("ffmpeg execution failed: %w\nffmpeg stderr:\n%s", err, stderr.String())
+		return fmt.Errorf("ffmpeg execution failed: %w\nffmpeg stderr:\n%s", err, stderr.String())
 	}
-
-	return fmt.Sprintf("Successfully merged videos to %s", outputFile), nil
+	return nil
 }
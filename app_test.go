@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestProbeRotation(t *testing.T) {
+	matrix := func(deg float64) []FFProbeSideData {
+		return []FFProbeSideData{{SideDataType: "Display Matrix", Rotation: deg}}
+	}
+	tests := []struct {
+		name string
+		s    FFProbeStream
+		want int
+	}{
+		{"none", FFProbeStream{}, 0},
+		{"rotate tag", FFProbeStream{Tags: map[string]string{"rotate": "90"}}, 90},
+		{"negative tag", FFProbeStream{Tags: map[string]string{"rotate": "-90"}}, 270},
+		{"matrix is counter-clockwise", FFProbeStream{SideDataList: matrix(-90)}, 90},
+		{"matrix ccw 90", FFProbeStream{SideDataList: matrix(90)}, 270},
+		{"matrix upside down", FFProbeStream{SideDataList: matrix(180)}, 180},
+		{"snapped", FFProbeStream{SideDataList: matrix(-89.6)}, 90},
+		{"full turn", FFProbeStream{Tags: map[string]string{"rotate": "360"}}, 0},
+		{"tag wins over matrix", FFProbeStream{Tags: map[string]string{"rotate": "180"}, SideDataList: matrix(-90)}, 180},
+		{"other side data", FFProbeStream{SideDataList: []FFProbeSideData{{SideDataType: "Stereo 3D", Rotation: 90}}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := probeRotation(tt.s); got != tt.want {
+				t.Errorf("probeRotation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplaySize(t *testing.T) {
+	tests := []struct {
+		w, h, rot    int
+		wantW, wantH int
+	}{
+		{1920, 1080, 0, 1920, 1080},
+		{1920, 1080, 90, 1080, 1920},
+		{1920, 1080, 180, 1920, 1080},
+		{1920, 1080, 270, 1080, 1920},
+	}
+	for _, tt := range tests {
+		if w, h := displaySize(tt.w, tt.h, tt.rot); w != tt.wantW || h != tt.wantH {
+			t.Errorf("displaySize(%d, %d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.rot, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"sync"
+)
+
+// Environment variables that point Stitcher at specific binaries.
+const (
+	envFFmpegPath  = "STITCHER_FFMPEG"
+	envFFprobePath = "STITCHER_FFPROBE"
+)
+
+// Resolved tool paths. Every ffmpeg/ffprobe invocation goes through
+// ffmpegBin/ffprobeBin so a path picked at runtime applies everywhere.
+var (
+	binMu       sync.RWMutex
+	ffmpegPath  = "ffmpeg"
+	ffprobePath = "ffprobe"
+)
+
+func ffmpegBin() string {
+	binMu.RLock()
+	defer binMu.RUnlock()
+	return ffmpegPath
+}
+
+func ffprobeBin() string {
+	binMu.RLock()
+	defer binMu.RUnlock()
+	return ffprobePath
+}
+
+func setBinaries(ffmpeg, ffprobe string) {
+	binMu.Lock()
+	defer binMu.Unlock()
+	ffmpegPath, ffprobePath = ffmpeg, ffprobe
+}
+
+// BinaryStatus describes where ffmpeg and ffprobe were found and whether they work.
+type BinaryStatus struct {
+	FFmpegPath   string `json:"ffmpegPath"`
+	FFprobePath  string `json:"ffprobePath"`
+	FFmpegError  string `json:"ffmpegError,omitempty"`
+	FFprobeError string `json:"ffprobeError,omitempty"`
+}
+
+// Ready reports whether both tools are usable.
+func (s BinaryStatus) Ready() bool {
+	return s.FFmpegError == "" && s.FFprobeError == ""
+}
+
+// exeName adds the platform's executable suffix.
+func exeName(tool string) string {
+	if goruntime.GOOS == "windows" {
+		return tool + ".exe"
+	}
+	return tool
+}
+
+// bundledCandidates lists where a packaged build may ship a tool: next to the
+// executable, in an ffmpeg/ or bin/ folder beside it, and in the macOS app
+// bundle's Resources.
+func bundledCandidates(tool string) []string {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	dir := filepath.Dir(exe)
+	name := exeName(tool)
+	candidates := []string{
+		filepath.Join(dir, name),
+		filepath.Join(dir, "ffmpeg", name),
+		filepath.Join(dir, "bin", name),
+	}
+	if goruntime.GOOS == "darwin" {
+		candidates = append(candidates, filepath.Join(dir, "..", "Resources", name))
+	}
+	return candidates
+}
+
+// findBinary resolves a tool: an explicit path wins, then the environment
+// variable, then a copy shipped with the app, then PATH.
+func findBinary(tool, explicit, envVar string) (string, error) {
+	if explicit != "" {
+		return explicit, validateBinary(tool, explicit)
+	}
+	if p := os.Getenv(envVar); p != "" {
+		return p, validateBinary(tool, p)
+	}
+	for _, p := range bundledCandidates(tool) {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			if validateBinary(tool, p) == nil {
+				return p, nil
+			}
+		}
+	}
+	p, err := exec.LookPath(tool)
+	if err != nil {
+		return tool, fmt.Errorf("%s not found: set its location in settings, set %s, or install it on PATH", tool, envVar)
+	}
+	return p, validateBinary(tool, p)
+}
+
+// validateBinary runs `<path> -version` and checks the tool identifies itself.
+func validateBinary(tool, path string) error {
+	out, err := exec.Command(path, "-version").Output()
+	if err != nil {
+		return fmt.Errorf("%s at %s could not be run: %v", tool, path, err)
+	}
+	if !strings.HasPrefix(string(out), tool+" version") {
+		return fmt.Errorf("%s is not %s", path, tool)
+	}
+	return nil
+}
+
+// resolveBinaries locates both tools and makes the found paths current.
+// Paths are applied even when validation fails so the status shows what was tried.
+func resolveBinaries(ffmpeg, ffprobe string) BinaryStatus {
+	var st BinaryStatus
+	var err error
+	if st.FFmpegPath, err = findBinary("ffmpeg", ffmpeg, envFFmpegPath); err != nil {
+		st.FFmpegError = err.Error()
+	}
+	if st.FFprobePath, err = findBinary("ffprobe", ffprobe, envFFprobePath); err != nil {
+		st.FFprobeError = err.Error()
+	}
+	setBinaries(st.FFmpegPath, st.FFprobePath)
+	return st
+}
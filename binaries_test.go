@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"testing"
+)
+
+// fakeTool writes a script that identifies itself as tool and fails
+// everything else, so capability detection stops early.
+func fakeTool(t *testing.T, dir, tool string) string {
+	path := filepath.Join(dir, tool)
+	script := "#!/bin/sh\n[ \"$1\" = -version ] || exit 1\necho '" + tool + " version 7.0'\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSetFFmpegPaths(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("uses shell scripts as fake binaries")
+	}
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("HOME", config)
+	defer setBinaries(ffmpegBin(), ffprobeBin())
+	settings, err := settingsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	ffmpeg, ffprobe := fakeTool(t, dir, "ffmpeg"), fakeTool(t, dir, "ffprobe")
+
+	a := &App{bins: BinaryStatus{FFmpegPath: ffmpeg, FFprobePath: ffprobe}}
+	a.applySettings(defaultSettings())
+	setBinaries(ffmpeg, ffprobe)
+	if _, err := a.SetFFmpegPaths(filepath.Join(dir, "missing"), ffprobe); err == nil {
+		t.Fatal("a missing ffmpeg was accepted")
+	}
+	if a.settings.FFmpegPath != "" {
+		t.Errorf("FFmpegPath = %q, want the broken path not kept", a.settings.FFmpegPath)
+	}
+	if _, err := os.Stat(settings); !os.IsNotExist(err) {
+		t.Error("settings were saved after a failed change")
+	}
+	if ffmpegBin() != ffmpeg || a.bins.FFmpegPath != ffmpeg {
+		t.Errorf("ffmpeg in use is %q, want the previous %q", ffmpegBin(), ffmpeg)
+	}
+
+	if _, err := a.SetFFmpegPaths(ffmpeg, ffprobe); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := loadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.FFmpegPath != ffmpeg || saved.FFprobePath != ffprobe {
+		t.Errorf("saved paths %q, %q", saved.FFmpegPath, saved.FFprobePath)
+	}
+}
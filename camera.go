@@ -0,0 +1,223 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// RecordingSession is one continuous camera recording that the camera split
+// into chapter files, in playback order.
+type RecordingSession struct {
+	Name     string      `json:"name"`   // suggested output name, e.g. "GoPro 2024-05-01 10-22-33"
+	Camera   string      `json:"camera"` // "GoPro", "DJI", "Dashcam" or "Camera"
+	Start    string      `json:"start"`  // RFC 3339, "" when the clips carry no time
+	Duration float64     `json:"duration"`
+	Clips    []VideoFile `json:"clips"`
+}
+
+var (
+	// HERO6 and later: G + encoding (H=AVC, X=HEVC, ...) + chapter + file number
+	goProNameRe = regexp.MustCompile(`^(?i)G([A-Z])(\d{2})(\d{4})\.mp4$`)
+	// HERO5 and earlier: GOPRnnnn is the first chapter, GPccnnnn the following ones
+	goProFirstRe   = regexp.MustCompile(`^(?i)GOPR(\d{4})\.mp4$`)
+	goProChapterRe = regexp.MustCompile(`^(?i)GP(\d{2})(\d{4})\.mp4$`)
+	// Date and time in a file name, as dashcams and newer DJI models write them
+	nameTimeRe = regexp.MustCompile(`(20\d\d)[-_]?(\d\d)[-_]?(\d\d)[-_T ]?(\d\d)[-_]?(\d\d)[-_]?(\d\d)`)
+	digitsRe   = regexp.MustCompile(`\d+`)
+)
+
+// sessionGapTolerance is how far (seconds) a chapter may start from the end
+// of the previous one. Clocks have one-second resolution and some cameras
+// take a moment to open the next file.
+const sessionGapTolerance = 3.0
+
+// goProChapter returns the recording a GoPro file belongs to and its chapter
+// number, or ok=false if the name isn't a GoPro chapter.
+func goProChapter(name string) (recording string, chapter int, ok bool) {
+	if m := goProNameRe.FindStringSubmatch(name); m != nil {
+		chapter, _ = strconv.Atoi(m[2])
+		return strings.ToUpper(m[1]) + m[3], chapter, true
+	}
+	if m := goProFirstRe.FindStringSubmatch(name); m != nil {
+		return "P" + m[1], 0, true
+	}
+	if m := goProChapterRe.FindStringSubmatch(name); m != nil {
+		chapter, _ = strconv.Atoi(m[1])
+		return "P" + m[2], chapter, true
+	}
+	return "", 0, false
+}
+
+// cameraKind guesses the camera from a file name.
+func cameraKind(name string) string {
+	switch {
+	case strings.HasPrefix(strings.ToUpper(name), "DJI_"):
+		return "DJI"
+	case nameTimeRe.MatchString(name):
+		return "Dashcam"
+	}
+	return "Camera"
+}
+
+// clipClock returns when the clip was recorded: its creation_time, or the
+// local time in its file name. Cameras without a time zone setting write
+// local time as UTC, so clocks are only compared between clips of the same
+// camera.
+func clipClock(v VideoFile) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, v.CreationTime); err == nil {
+		return t.Local(), true
+	}
+	m := nameTimeRe.FindStringSubmatch(filepath.Base(v.Path))
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102150405", strings.Join(m[1:], ""), time.Local)
+	return t, err == nil
+}
+
+// chapterContinues reports whether next was recorded right after prev.
+// Cameras stamp either the start or the end of the recording, so the gap is
+// checked both ways.
+func chapterContinues(prev, next VideoFile) bool {
+	p, ok1 := clipClock(prev)
+	n, ok2 := clipClock(next)
+	if !ok1 || !ok2 {
+		return false
+	}
+	d := n.Sub(p).Seconds()
+	return math.Abs(d-prev.Duration) <= sessionGapTolerance || math.Abs(d-next.Duration) <= sessionGapTolerance
+}
+
+// chapterFamily is the key clips of one camera and folder share: the file
+// name with its numbers masked, so front and rear dashcam channels or two
+// cameras in one folder aren't mixed.
+func chapterFamily(v VideoFile) string {
+	return filepath.Dir(v.Path) + "|" + digitsRe.ReplaceAllString(strings.ToUpper(filepath.Base(v.Path)), "#")
+}
+
+// groupSessions sorts clips into recording sessions. GoPro chapters are
+// grouped by their file number; other cameras by creation-time continuity
+// within a family of similarly named files. Clips that can't be
+// stream-copied together never share a session.
+func groupSessions(vs []VideoFile) []RecordingSession {
+	type chapter struct {
+		v VideoFile
+		n int
+	}
+	goPro := map[string][]chapter{}
+	families := map[string][]VideoFile{}
+	for _, v := range vs {
+		if rec, n, ok := goProChapter(filepath.Base(v.Path)); ok {
+			key := filepath.Dir(v.Path) + "|" + rec
+			goPro[key] = append(goPro[key], chapter{v, n})
+			continue
+		}
+		families[chapterFamily(v)] = append(families[chapterFamily(v)], v)
+	}
+
+	var runs [][]VideoFile
+	var cameras []string
+	for _, chapters := range goPro {
+		slices.SortFunc(chapters, func(a, b chapter) int { return a.n - b.n })
+		run := []VideoFile{chapters[0].v}
+		for _, c := range chapters[1:] {
+			// A camera mode change between chapters can't be joined by copy
+			if !remuxable([]VideoFile{run[len(run)-1], c.v}) {
+				runs, cameras = append(runs, run), append(cameras, "GoPro")
+				run = nil
+			}
+			run = append(run, c.v)
+		}
+		runs, cameras = append(runs, run), append(cameras, "GoPro")
+	}
+	for _, clips := range families {
+		slices.SortFunc(clips, func(a, b VideoFile) int {
+			ta, _ := clipClock(a)
+			tb, _ := clipClock(b)
+			return cmp.Or(ta.Compare(tb), naturalCompare(a.Path, b.Path))
+		})
+		camera := cameraKind(filepath.Base(clips[0].Path))
+		run := []VideoFile{clips[0]}
+		for _, v := range clips[1:] {
+			prev := run[len(run)-1]
+			if !chapterContinues(prev, v) || !remuxable([]VideoFile{prev, v}) {
+				runs, cameras = append(runs, run), append(cameras, camera)
+				run = nil
+			}
+			run = append(run, v)
+		}
+		runs, cameras = append(runs, run), append(cameras, camera)
+	}
+
+	sessions := make([]RecordingSession, len(runs))
+	for i, run := range runs {
+		s := RecordingSession{Camera: cameras[i], Clips: run, Duration: totalDuration(run)}
+		if t, ok := clipClock(run[0]); ok {
+			s.Start = t.Format(time.RFC3339)
+			s.Name = fmt.Sprintf("%s %s", s.Camera, t.Format("2006-01-02 15-04-05"))
+		} else {
+			s.Name = fmt.Sprintf("%s %s", s.Camera, strings.TrimSuffix(run[0].FileName, filepath.Ext(run[0].FileName)))
+		}
+		sessions[i] = s
+	}
+	slices.SortFunc(sessions, func(a, b RecordingSession) int {
+		return cmp.Or(cmp.Compare(a.Start, b.Start), cmp.Compare(a.Clips[0].Path, b.Clips[0].Path))
+	})
+	return sessions
+}
+
+// ImportCameraFolder scans a camera card or folder, including subfolders
+// such as DCIM/100GOPRO, and groups the clips into recording sessions.
+func (a *App) ImportCameraFolder() ([]RecordingSession, error) {
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            "Select Camera Folder",
+		DefaultDirectory: a.settings.LastInputDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return []RecordingSession{}, nil
+	}
+	a.settings.LastInputDir = dir
+	a.persistSettings()
+
+	paths, err := findVideoFiles(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	sessions := groupSessions(a.probeFiles(paths, nil))
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": fmt.Sprintf("Found %d recording session(s) in %d file(s)", len(sessions), len(paths)),
+	})
+	return sessions, nil
+}
+
+// QueueSessions queues one merge per session. Single-file sessions have
+// nothing to join and are skipped.
+func (a *App) QueueSessions(sessions []RecordingSession) ([]MergeJob, error) {
+	var jobs []MergeJob
+	for _, s := range sessions {
+		if len(s.Clips) < 2 {
+			continue
+		}
+		job, err := a.QueueMerge(s.Name, s.Clips)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no session has more than one file to merge")
+	}
+	return jobs, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGoProChapter(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+		chapter   int
+		ok        bool
+	}{
+		{"GH010123.MP4", "H0123", 1, true},
+		{"gx020123.mp4", "X0123", 2, true},
+		{"GOPR0456.MP4", "P0456", 0, true},
+		{"GP010456.MP4", "P0456", 1, true},
+		{"DJI_0001.MP4", "", 0, false},
+		{"GH010123.MOV", "", 0, false},
+	}
+	for _, tt := range tests {
+		rec, n, ok := goProChapter(tt.name)
+		if rec != tt.recording || n != tt.chapter || ok != tt.ok {
+			t.Errorf("goProChapter(%s) = %q, %d, %v; want %q, %d, %v", tt.name, rec, n, ok, tt.recording, tt.chapter, tt.ok)
+		}
+	}
+}
+
+func TestGroupSessions(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+	// clip is a chapter at path recorded offset seconds in; -1 = no recording time
+	clip := func(path string, offset float64) VideoFile {
+		return testClip(filepath.Base(path), func(v *VideoFile) {
+			v.Path = path
+			if offset >= 0 {
+				v.CreationTime = start.Add(time.Duration(offset * float64(time.Second))).Format(time.RFC3339)
+			}
+		})
+	}
+	tests := []struct {
+		name string
+		vs   []VideoFile
+		want [][]string // clip paths per session
+	}{
+		{
+			"GoPro chapters by file number",
+			[]VideoFile{clip("/c/GH020123.MP4", -1), clip("/c/GH010123.MP4", -1), clip("/c/GH010124.MP4", -1)},
+			[][]string{{"/c/GH010123.MP4", "/c/GH020123.MP4"}, {"/c/GH010124.MP4"}},
+		},
+		{
+			"continuous clips of one camera",
+			[]VideoFile{clip("/c/DJI_0002.MP4", 60), clip("/c/DJI_0001.MP4", 0), clip("/c/DJI_0003.MP4", 600)},
+			[][]string{{"/c/DJI_0001.MP4", "/c/DJI_0002.MP4"}, {"/c/DJI_0003.MP4"}},
+		},
+		{
+			"dashcam channels stay apart",
+			[]VideoFile{
+				clip("/c/20240501_100000_F.MP4", 0), clip("/c/20240501_100100_F.MP4", 60),
+				clip("/c/20240501_100000_R.MP4", 0), clip("/c/20240501_100100_R.MP4", 60),
+			},
+			[][]string{
+				{"/c/20240501_100000_F.MP4", "/c/20240501_100100_F.MP4"},
+				{"/c/20240501_100000_R.MP4", "/c/20240501_100100_R.MP4"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			for _, s := range groupSessions(tt.vs) {
+				var paths []string
+				for _, v := range s.Clips {
+					paths = append(paths, v.Path)
+				}
+				got = append(got, paths)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("sessions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupSessionsSplitsModeChange(t *testing.T) {
+	a := testClip("GH010123.MP4", func(v *VideoFile) { v.Resolution = "3840x2160" })
+	b := testClip("GH020123.MP4")
+	if got := len(groupSessions([]VideoFile{a, b})); got != 2 {
+		t.Errorf("got %d session(s), want 2: the chapters can't be joined by copy", got)
+	}
+}
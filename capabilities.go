@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Oldest ffmpeg release Stitcher is tested with, and the libavcodec version
+// it shipped, used for git builds that report no release number.
+const (
+	minFFmpegMajor     = 4
+	minFFmpegMinor     = 4
+	minLibavcodecMajor = 58
+	minLibavcodecMinor = 134
+)
+
+// FFmpegCapabilities is the parsed feature report of the ffmpeg binary.
+type FFmpegCapabilities struct {
+	Version        string   `json:"version"`        // as reported, e.g. "6.1.1-3ubuntu5" or "N-113000-g..."
+	Libavcodec     string   `json:"libavcodec"`     // e.g. "60.31.102"
+	MinimumVersion string   `json:"minimumVersion"` // oldest supported release
+	Supported      bool     `json:"supported"`      // version meets the minimum
+	BuildConfig    []string `json:"buildConfig"`    // configure flags
+	Encoders       []string `json:"encoders"`
+	Decoders       []string `json:"decoders"`
+	Filters        []string `json:"filters"`
+
+	// HardwareEncoders holds the trial encode result of every listed hardware
+	// encoder; nil while the checks are still running.
+	HardwareEncoders map[string]bool `json:"hardwareEncoders"`
+}
+
+var (
+	ffmpegVersionRe = regexp.MustCompile(`^ffmpeg version (\S+)`)
+	releaseRe       = regexp.MustCompile(`^n?(\d+)\.(\d+)`)
+	libavcodecRe    = regexp.MustCompile(`(?m)^libavcodec\s+(\d+)\.\s*(\d+)\.\s*(\d+)`)
+)
+
+// detectCapabilities runs ffmpeg's -version, -buildconf, -encoders, -decoders
+// and -filters and parses them into a capability report.
+func detectCapabilities() (*FFmpegCapabilities, error) {
+	run := func(flag string) (string, error) {
+		out, err := exec.Command(ffmpegBin(), "-hide_banner", flag).Output()
+		if err != nil {
+			return "", fmt.Errorf("ffmpeg %s failed: %w", flag, err)
+		}
+		return string(out), nil
+	}
+
+	// -hide_banner would drop the version line, so run -version plainly
+	out, err := exec.Command(ffmpegBin(), "-version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg -version failed: %w", err)
+	}
+	caps := &FFmpegCapabilities{
+		MinimumVersion: fmt.Sprintf("%d.%d", minFFmpegMajor, minFFmpegMinor),
+	}
+	caps.parseVersion(string(out))
+
+	if out, err := run("-buildconf"); err == nil {
+		caps.BuildConfig = parseBuildConf(out)
+	}
+	enc, err := run("-encoders")
+	if err != nil {
+		return nil, err
+	}
+	caps.Encoders = parseCodecList(enc)
+	if out, err := run("-decoders"); err == nil {
+		caps.Decoders = parseCodecList(out)
+	}
+	if out, err := run("-filters"); err == nil {
+		caps.Filters = parseFilterList(out)
+	}
+	return caps, nil
+}
+
+func (c *FFmpegCapabilities) parseVersion(out string) {
+	firstLine, _, _ := strings.Cut(out, "\n")
+	if m := ffmpegVersionRe.FindStringSubmatch(firstLine); m != nil {
+		c.Version = m[1]
+	}
+	var avMajor, avMinor int
+	if m := libavcodecRe.FindStringSubmatch(out); m != nil {
+		avMajor, _ = strconv.Atoi(m[1])
+		avMinor, _ = strconv.Atoi(m[2])
+		c.Libavcodec = fmt.Sprintf("%s.%s.%s", m[1], m[2], m[3])
+	}
+
+	if m := releaseRe.FindStringSubmatch(c.Version); m != nil {
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		c.Supported = major > minFFmpegMajor || (major == minFFmpegMajor && minor >= minFFmpegMinor)
+		return
+	}
+	// Git snapshots ("N-113000-g...") carry no release number; judge by libavcodec
+	c.Supported = avMajor > minLibavcodecMajor || (avMajor == minLibavcodecMajor && avMinor >= minLibavcodecMinor)
+}
+
+// parseBuildConf returns the configure flags from `ffmpeg -buildconf`.
+func parseBuildConf(out string) []string {
+	var flags []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "--") {
+			flags = append(flags, line)
+		}
+	}
+	return flags
+}
+
+// parseCodecList extracts names from `ffmpeg -encoders`/`-decoders`, whose
+// entries follow a "------" separator as "<6 flag chars> <name> <description>".
+func parseCodecList(out string) []string {
+	var names []string
+	started := false
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if !started {
+			started = strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) >= 2 && len(fields[0]) == 6 {
+			names = append(names, fields[1])
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// parseFilterList extracts names from `ffmpeg -filters`, whose entries read
+// "<flags> <name> <in>-><out> <description>".
+func parseFilterList(out string) []string {
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			names = append(names, fields[1])
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// hasDisplayRotation reports whether ffmpeg takes -display_rotation, added
+// in 6.0 (libavcodec 60).
+func (c *FFmpegCapabilities) hasDisplayRotation() bool {
+	if c == nil {
+		return false
+	}
+	major, _, _ := strings.Cut(c.Libavcodec, ".")
+	n, _ := strconv.Atoi(major)
+	return n >= 60
+}
+
+func (c *FFmpegCapabilities) hasEncoder(name string) bool {
+	_, ok := slices.BinarySearch(c.Encoders, name)
+	return ok
+}
+
+func (c *FFmpegCapabilities) hasDecoder(name string) bool {
+	_, ok := slices.BinarySearch(c.Decoders, name)
+	return ok
+}
+
+// canDecode matches an ffprobe codec name against the decoder list. Builds
+// may only ship a wrapper for a codec (libdav1d for av1, h264_cuvid, ...),
+// whose names contain the codec name.
+func (c *FFmpegCapabilities) canDecode(codec string) bool {
+	if c.hasDecoder(codec) {
+		return true
+	}
+	for _, d := range c.Decoders {
+		if strings.Contains(d, codec) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *FFmpegCapabilities) hasFilter(name string) bool {
+	_, ok := slices.BinarySearch(c.Filters, name)
+	return ok
+}
+
+// jobRequirements lists the ffmpeg features a job depends on.
+type jobRequirements struct {
+	encoders []string
+	decoders []string
+	filters  []string
+}
+
+// check reports every requirement the ffmpeg build can't satisfy, so a job
+// fails before it starts instead of halfway through.
+func (c *FFmpegCapabilities) check(req jobRequirements) error {
+	if c == nil {
+		return fmt.Errorf("ffmpeg capabilities are unknown; check the ffmpeg installation")
+	}
+	var missing []string
+	if !c.Supported {
+		missing = append(missing, fmt.Sprintf("ffmpeg %s is older than the minimum supported %s", c.Version, c.MinimumVersion))
+	}
+	for _, e := range req.encoders {
+		if !c.hasEncoder(e) {
+			missing = append(missing, "encoder "+e)
+		}
+	}
+	for _, d := range req.decoders {
+		if d != "" && !c.canDecode(d) {
+			missing = append(missing, "decoder "+d)
+		}
+	}
+	for _, f := range req.filters {
+		if !c.hasFilter(f) {
+			missing = append(missing, "filter "+f)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("this ffmpeg build can't run the job, missing: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
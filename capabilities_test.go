@@ -0,0 +1,110 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name           string
+		out            string
+		wantVersion    string
+		wantLibavcodec string
+		wantSupported  bool
+	}{
+		{"distro release", "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nlibavcodec     60. 31.102 / 60. 31.102\n",
+			"6.1.1-3ubuntu5", "60.31.102", true},
+		{"minimum release", "ffmpeg version n4.4 Copyright (c) 2000-2021\nlibavcodec     58.134.100 / 58.134.100\n",
+			"n4.4", "58.134.100", true},
+		{"too old", "ffmpeg version 4.2.7-0ubuntu0.1 Copyright (c) 2000-2022\nlibavcodec     58. 54.100 / 58. 54.100\n",
+			"4.2.7-0ubuntu0.1", "58.54.100", false},
+		{"git snapshot", "ffmpeg version N-113000-g1234abcd Copyright (c) 2000-2024\nlibavcodec     61.  3.100 / 61.  3.100\n",
+			"N-113000-g1234abcd", "61.3.100", true},
+		{"old git snapshot", "ffmpeg version N-99000-gabcdef Copyright (c) 2000-2020\nlibavcodec     58. 91.100 / 58. 91.100\n",
+			"N-99000-gabcdef", "58.91.100", false},
+		{"garbage", "not ffmpeg\n", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c FFmpegCapabilities
+			c.parseVersion(tt.out)
+			if c.Version != tt.wantVersion || c.Libavcodec != tt.wantLibavcodec || c.Supported != tt.wantSupported {
+				t.Errorf("parseVersion = %q, %q, supported %v, want %q, %q, supported %v",
+					c.Version, c.Libavcodec, c.Supported, tt.wantVersion, tt.wantLibavcodec, tt.wantSupported)
+			}
+		})
+	}
+}
+
+func TestParseLists(t *testing.T) {
+	encoders := `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC (codec h264)
+ V....D h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+	if got, want := parseCodecList(encoders), []string{"aac", "h264_nvenc", "libx264"}; !slices.Equal(got, want) {
+		t.Errorf("parseCodecList = %q, want %q", got, want)
+	}
+
+	filters := `Filters:
+  T.. = Timeline support
+ ... concat            N->N       Concatenate audio and video streams.
+ TSC scale             V->V       Scale the input video size and/or convert the image format.
+ T.. blackdetect       V->V       Detect video intervals that are (almost) black.
+`
+	if got, want := parseFilterList(filters), []string{"blackdetect", "concat", "scale"}; !slices.Equal(got, want) {
+		t.Errorf("parseFilterList = %q, want %q", got, want)
+	}
+
+	buildconf := "  configuration:\n    --enable-gpl\n    --enable-libx264\n"
+	if got, want := parseBuildConf(buildconf), []string{"--enable-gpl", "--enable-libx264"}; !slices.Equal(got, want) {
+		t.Errorf("parseBuildConf = %q, want %q", got, want)
+	}
+}
+
+func TestCapabilitiesCheck(t *testing.T) {
+	caps := &FFmpegCapabilities{
+		Version: "6.1", MinimumVersion: "4.4", Supported: true,
+		Encoders: []string{"aac", "libx264"},
+		Decoders: []string{"aac", "h264", "libdav1d"},
+		Filters:  []string{"concat", "scale"},
+	}
+	tests := []struct {
+		name    string
+		caps    *FFmpegCapabilities
+		req     jobRequirements
+		missing []string // substrings of the error; nil means no error
+	}{
+		{"satisfied", caps, jobRequirements{encoders: []string{"libx264"}, decoders: []string{"h264"}, filters: []string{"scale"}}, nil},
+		{"decoder wrapper", caps, jobRequirements{decoders: []string{"av1"}}, nil},
+		{"unknown source codec", caps, jobRequirements{decoders: []string{""}}, nil},
+		{"missing", caps, jobRequirements{encoders: []string{"libx265"}, decoders: []string{"prores"}, filters: []string{"zscale"}},
+			[]string{"encoder libx265", "decoder prores", "filter zscale"}},
+		{"too old", &FFmpegCapabilities{Version: "4.2", MinimumVersion: "4.4"}, jobRequirements{}, []string{"older than the minimum supported 4.4"}},
+		{"unknown", nil, jobRequirements{}, []string{"capabilities are unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.caps.check(tt.req)
+			if tt.missing == nil {
+				if err != nil {
+					t.Errorf("check = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("check = nil, want an error mentioning %q", tt.missing)
+			}
+			for _, m := range tt.missing {
+				if !strings.Contains(err.Error(), m) {
+					t.Errorf("check = %v, want it to mention %q", err, m)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HDRMode controls what normalization does with HDR sources.
+type HDRMode string
+
+const (
+	// HDRModeTonemap converts HDR clips to SDR BT.709 so they play correctly everywhere.
+	HDRModeTonemap HDRMode = "tonemap"
+	// HDRModeKeep preserves HDR by encoding 10-bit HEVC with the source color tags.
+	HDRModeKeep HDRMode = "keep"
+)
+
+var pixFmtDepthRe = regexp.MustCompile(`(\d+)(le|be)$`)
+
+// pixFmtBitDepth guesses the per-component bit depth from a pixel format name,
+// e.g. yuv420p10le -> 10, p010le -> 10, yuv420p -> 8.
+func pixFmtBitDepth(pixFmt string) int {
+	if strings.HasPrefix(pixFmt, "p010") {
+		return 10
+	}
+	if strings.HasPrefix(pixFmt, "p016") {
+		return 16
+	}
+	if m := pixFmtDepthRe.FindStringSubmatch(pixFmt); m != nil {
+		if d, err := strconv.Atoi(m[1]); err == nil && d > 8 && d <= 16 {
+			return d
+		}
+	}
+	return 8
+}
+
+// probeBitDepth prefers the stream's bits_per_raw_sample and falls back to the pixel format.
+func probeBitDepth(s FFProbeStream) int {
+	if d, err := strconv.Atoi(s.BitsPerRawSample); err == nil && d > 0 {
+		return d
+	}
+	return pixFmtBitDepth(s.PixFmt)
+}
+
+// isHDRTransfer reports whether a transfer characteristic is PQ (HDR10) or HLG.
+func isHDRTransfer(trc string) bool {
+	return trc == "smpte2084" || trc == "arib-std-b67"
+}
+
+func isHDR(v VideoFile) bool {
+	return isHDRTransfer(v.ColorTransfer)
+}
+
+// tonemapFilter converts an HDR clip to SDR BT.709. The input tags are passed
+// explicitly because zscale refuses to guess when a container leaves them out.
+func tonemapFilter(v VideoFile) string {
+	pin := v.ColorPrimaries
+	if pin == "" || pin == "unknown" {
+		pin = "bt2020"
+	}
+	matrix := v.ColorSpace
+	if matrix == "" || matrix == "unknown" {
+		matrix = "bt2020nc"
+	}
+	return fmt.Sprintf(
+		"zscale=tin=%s:pin=%s:min=%s:t=linear:npl=100,format=gbrpf32le,"+
+			"zscale=p=bt709,tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv",
+		v.ColorTransfer, pin, matrix)
+}
+
+// scaledRational returns an ffprobe rational such as "34000/50000" in the
+// given unit, e.g. 34000 for unit 50000.
+func scaledRational(r string, unit float64) int {
+	return int(math.Round(parseFrameRate(r) * unit))
+}
+
+// probeMasteringDisplay formats the stream's mastering display side data as
+// x265's master-display value: chromaticities in 0.00002 and luminance in
+// 0.0001 cd/m² steps. Returns "" when the stream carries none.
+func probeMasteringDisplay(s FFProbeStream) string {
+	for _, sd := range s.SideDataList {
+		if sd.SideDataType != "Mastering display metadata" || sd.MaxLuminance == "" {
+			continue
+		}
+		xy := func(x, y string) string {
+			return fmt.Sprintf("(%d,%d)", scaledRational(x, 50000), scaledRational(y, 50000))
+		}
+		return "G" + xy(sd.GreenX, sd.GreenY) + "B" + xy(sd.BlueX, sd.BlueY) + "R" + xy(sd.RedX, sd.RedY) +
+			"WP" + xy(sd.WhitePointX, sd.WhitePointY) +
+			fmt.Sprintf("L(%d,%d)", scaledRational(sd.MaxLuminance, 10000), scaledRational(sd.MinLuminance, 10000))
+	}
+	return ""
+}
+
+// probeMaxCLL formats the stream's content light level side data as x265's
+// max-cll value. Returns "" when the stream carries none.
+func probeMaxCLL(s FFProbeStream) string {
+	for _, sd := range s.SideDataList {
+		if sd.SideDataType == "Content light level metadata" && sd.MaxContent > 0 {
+			return fmt.Sprintf("%d,%d", sd.MaxContent, sd.MaxAverage)
+		}
+	}
+	return ""
+}
+
+// hdrPlan is the result of checking a clip list against the HDR mode.
+type hdrPlan struct {
+	keep     bool   // encode 10-bit HEVC and keep HDR tags
+	transfer string // transfer to tag the output with when keep is set
+	tonemap  []bool // per clip: needs tone mapping to SDR
+	tonemapN int
+
+	// HDR10 static metadata for the output when keep is set, see hdrMetadata
+	masterDisplay string
+	maxCLL        string
+}
+
+// hdrMetadata picks the HDR10 static metadata for the merged output. The
+// mastering display is only kept when every clip was graded on the same one;
+// MaxCLL/MaxFALL are the largest of all clips, and dropped if any clip lacks them.
+func hdrMetadata(vs []VideoFile) (masterDisplay, maxCLL string) {
+	masterDisplay = vs[0].MasteringDisplay
+	var cll, fall int
+	for _, v := range vs {
+		if v.MasteringDisplay != masterDisplay {
+			masterDisplay = ""
+		}
+		var c, f int
+		if _, err := fmt.Sscanf(v.MaxCLL, "%d,%d", &c, &f); err != nil {
+			cll = -1
+		}
+		if cll >= 0 {
+			cll, fall = max(cll, c), max(fall, f)
+		}
+	}
+	if cll > 0 {
+		maxCLL = fmt.Sprintf("%d,%d", cll, fall)
+	}
+	return masterDisplay, maxCLL
+}
+
+// planHDR decides how each clip is handled. HDR and SDR clips are never mixed
+// silently: keep mode fails on mixed input, tonemap mode converts every HDR clip.
+func planHDR(mode HDRMode, vs []VideoFile) (hdrPlan, error) {
+	plan := hdrPlan{tonemap: make([]bool, len(vs))}
+	var hdrNames, sdrNames []string
+	transfers := map[string]bool{}
+	for _, v := range vs {
+		if isHDR(v) {
+			hdrNames = append(hdrNames, v.FileName)
+			transfers[v.ColorTransfer] = true
+		} else {
+			sdrNames = append(sdrNames, v.FileName)
+		}
+	}
+	if len(hdrNames) == 0 {
+		return plan, nil
+	}
+
+	if mode == HDRModeKeep {
+		if len(sdrNames) > 0 {
+			return plan, fmt.Errorf("cannot keep HDR: %s are SDR while %s are HDR; switch HDR handling to tone mapping or remove the SDR clips",
+				strings.Join(sdrNames, ", "), strings.Join(hdrNames, ", "))
+		}
+		if len(transfers) > 1 {
+			return plan, fmt.Errorf("cannot keep HDR: clips mix PQ (HDR10) and HLG; switch HDR handling to tone mapping")
+		}
+		plan.keep = true
+		plan.transfer = vs[0].ColorTransfer
+		plan.masterDisplay, plan.maxCLL = hdrMetadata(vs)
+		return plan, nil
+	}
+
+	for i, v := range vs {
+		if isHDR(v) {
+			plan.tonemap[i] = true
+			plan.tonemapN++
+		}
+	}
+	return plan, nil
+}
+
+// colorInfo is a clip's color description, with untagged values filled in the
+// way players guess them. Matrix names use the scale filter's vocabulary.
+type colorInfo struct {
+	matrix    string // bt601, bt709, bt2020, fcc, smpte240m
+	rng       string // tv (limited) or pc (full)
+	primaries string
+}
+
+// clipColor resolves the effective colorspace, range and primaries of a clip.
+// Untagged SD material is assumed BT.601 and untagged HD material BT.709;
+// yuvj* pixel formats are full range.
+func clipColor(v VideoFile) colorInfo {
+	var c colorInfo
+	switch v.ColorSpace {
+	case "bt709":
+		c.matrix = "bt709"
+	case "smpte170m", "bt470bg":
+		c.matrix = "bt601"
+	case "bt2020nc", "bt2020c":
+		c.matrix = "bt2020"
+	case "fcc", "smpte240m":
+		c.matrix = v.ColorSpace
+	default:
+		var w, h int
+		fmt.Sscanf(v.Resolution, "%dx%d", &w, &h)
+		if min(w, h) > 0 && min(w, h) <= 576 {
+			c.matrix = "bt601"
+		} else {
+			c.matrix = "bt709"
+		}
+	}
+
+	switch {
+	case v.ColorRange == "pc" || v.ColorRange == "tv":
+		c.rng = v.ColorRange
+	case strings.HasPrefix(v.PixelFormat, "yuvj"):
+		c.rng = "pc"
+	default:
+		c.rng = "tv"
+	}
+
+	c.primaries = v.ColorPrimaries
+	if c.primaries == "" || c.primaries == "unknown" {
+		switch c.matrix {
+		case "bt601":
+			c.primaries = "smpte170m"
+		case "bt2020":
+			c.primaries = "bt2020"
+		default:
+			c.primaries = "bt709"
+		}
+	}
+	return c
+}
+
+// colorConvertArgs returns scale filter options that convert a clip's matrix
+// and range to the target. Primaries are not remapped: between BT.601 and
+// BT.709 the difference is far smaller than a wrong matrix or range.
+func colorConvertArgs(in colorInfo, outMatrix string) string {
+	return fmt.Sprintf("in_color_matrix=%s:out_color_matrix=%s:in_range=%s:out_range=tv", in.matrix, outMatrix, in.rng)
+}
+
+// hdrColorTags returns the output tags that keep HDR10/HLG signalling intact.
+// libx265 also needs the values in its own parameters to write them into the
+// bitstream's VUI and SEI, along with the mastering display and MaxCLL SEI.
+// The hardware HEVC encoders have no option for the static metadata, so their
+// output is tagged HDR10 but without it.
+func hdrColorTags(p hdrPlan, encoder string) []string {
+	tags := []string{"-color_primaries", "bt2020", "-color_trc", p.transfer, "-colorspace", "bt2020nc"}
+	if encoder == "libx265" {
+		params := fmt.Sprintf("hdr-opt=1:repeat-headers=1:colorprim=bt2020:transfer=%s:colormatrix=bt2020nc", p.transfer)
+		if p.masterDisplay != "" {
+			params += ":master-display=" + p.masterDisplay
+		}
+		if p.maxCLL != "" {
+			params += ":max-cll=" + p.maxCLL
+		}
+		tags = append(tags, "-x265-params", params)
+	}
+	return tags
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// hdr10 is the side data ffprobe reports for a typical P3-D65 HDR10 master.
+var hdr10 = []FFProbeSideData{
+	{
+		SideDataType: "Mastering display metadata",
+		RedX:         "34000/50000", RedY: "16000/50000",
+		GreenX: "13250/50000", GreenY: "34500/50000",
+		BlueX: "7500/50000", BlueY: "3000/50000",
+		WhitePointX: "15635/50000", WhitePointY: "16450/50000",
+		MinLuminance: "50/10000", MaxLuminance: "10000000/10000",
+	},
+	{SideDataType: "Content light level metadata", MaxContent: 1000, MaxAverage: 400},
+}
+
+const hdr10Display = "G(13250,34500)B(7500,3000)R(34000,16000)WP(15635,16450)L(10000000,50)"
+
+func TestProbeHDRMetadata(t *testing.T) {
+	s := FFProbeStream{SideDataList: hdr10}
+	if got := probeMasteringDisplay(s); got != hdr10Display {
+		t.Errorf("probeMasteringDisplay = %q, want %q", got, hdr10Display)
+	}
+	if got := probeMaxCLL(s); got != "1000,400" {
+		t.Errorf("probeMaxCLL = %q, want %q", got, "1000,400")
+	}
+
+	// ffprobe reduces some rationals and lists luminance without chromaticities
+	reduced := FFProbeStream{SideDataList: []FFProbeSideData{{
+		SideDataType: "Mastering display metadata",
+		RedX:         "17/25", RedY: "8/25", GreenX: "53/200", GreenY: "69/100",
+		BlueX: "3/20", BlueY: "3/50", WhitePointX: "3127/10000", WhitePointY: "329/1000",
+		MinLuminance: "1/10000", MaxLuminance: "1000/1",
+	}}}
+	if got, want := probeMasteringDisplay(reduced), "G(13250,34500)B(7500,3000)R(34000,16000)WP(15635,16450)L(10000000,1)"; got != want {
+		t.Errorf("probeMasteringDisplay = %q, want %q", got, want)
+	}
+
+	if got := probeMasteringDisplay(FFProbeStream{}); got != "" {
+		t.Errorf("probeMasteringDisplay without side data = %q, want \"\"", got)
+	}
+	if got := probeMaxCLL(FFProbeStream{}); got != "" {
+		t.Errorf("probeMaxCLL without side data = %q, want \"\"", got)
+	}
+}
+
+func TestHDRMetadata(t *testing.T) {
+	hdr := func(name, display, cll string) VideoFile {
+		return testClip(name, func(v *VideoFile) {
+			v.ColorTransfer = "smpte2084"
+			v.MasteringDisplay = display
+			v.MaxCLL = cll
+		})
+	}
+	other := "G(8500,39850)B(6550,2300)R(35400,14600)WP(15635,16450)L(40000000,50)"
+	tests := []struct {
+		name        string
+		vs          []VideoFile
+		wantDisplay string
+		wantCLL     string
+	}{
+		{"single clip", []VideoFile{hdr("a", hdr10Display, "1000,400")}, hdr10Display, "1000,400"},
+		{"largest light level", []VideoFile{hdr("a", hdr10Display, "1000,400"), hdr("b", hdr10Display, "800,550")}, hdr10Display, "1000,550"},
+		{"different masters", []VideoFile{hdr("a", hdr10Display, "1000,400"), hdr("b", other, "1000,400")}, "", "1000,400"},
+		{"light level missing on one clip", []VideoFile{hdr("a", hdr10Display, "1000,400"), hdr("b", hdr10Display, "")}, hdr10Display, ""},
+		{"no metadata", []VideoFile{hdr("a", "", ""), hdr("b", "", "")}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			display, cll := hdrMetadata(tt.vs)
+			if display != tt.wantDisplay || cll != tt.wantCLL {
+				t.Errorf("hdrMetadata = %q, %q, want %q, %q", display, cll, tt.wantDisplay, tt.wantCLL)
+			}
+		})
+	}
+}
+
+func TestHDRColorTagsMetadata(t *testing.T) {
+	p := hdrPlan{keep: true, transfer: "smpte2084", masterDisplay: hdr10Display, maxCLL: "1000,400"}
+
+	got := hdrColorTags(p, "libx265")
+	i := slices.Index(got, "-x265-params")
+	if i < 0 {
+		t.Fatalf("hdrColorTags = %q, want -x265-params", got)
+	}
+	want := "hdr-opt=1:repeat-headers=1:colorprim=bt2020:transfer=smpte2084:colormatrix=bt2020nc" +
+		":master-display=" + hdr10Display + ":max-cll=1000,400"
+	if got[i+1] != want {
+		t.Errorf("x265 params = %q, want %q", got[i+1], want)
+	}
+
+	if got := hdrColorTags(p, "hevc_nvenc"); slices.Contains(got, "-x265-params") {
+		t.Errorf("hdrColorTags for hevc_nvenc = %q, want no x265 params", got)
+	}
+}
+
+func TestPlanHDR(t *testing.T) {
+	sdr := func(name string) VideoFile { return testClip(name) }
+	hdr := func(name, transfer string) VideoFile {
+		return testClip(name, func(v *VideoFile) {
+			v.ColorTransfer = transfer
+			v.ColorPrimaries = "bt2020"
+			v.ColorSpace = "bt2020nc"
+			v.PixelFormat = "yuv420p10le"
+			v.BitDepth = 10
+		})
+	}
+	tests := []struct {
+		name         string
+		mode         HDRMode
+		vs           []VideoFile
+		wantErr      bool
+		wantKeep     bool
+		wantTransfer string
+		wantTonemap  []bool
+	}{
+		{"all SDR keep", HDRModeKeep, []VideoFile{sdr("a"), sdr("b")}, false, false, "", []bool{false, false}},
+		{"all SDR tonemap", HDRModeTonemap, []VideoFile{sdr("a"), sdr("b")}, false, false, "", []bool{false, false}},
+		{"keep PQ", HDRModeKeep, []VideoFile{hdr("a", "smpte2084"), hdr("b", "smpte2084")}, false, true, "smpte2084", []bool{false, false}},
+		{"keep HLG", HDRModeKeep, []VideoFile{hdr("a", "arib-std-b67")}, false, true, "arib-std-b67", []bool{false}},
+		{"keep mixed SDR", HDRModeKeep, []VideoFile{hdr("a", "smpte2084"), sdr("b")}, true, false, "", nil},
+		{"keep mixed PQ and HLG", HDRModeKeep, []VideoFile{hdr("a", "smpte2084"), hdr("b", "arib-std-b67")}, true, false, "", nil},
+		{"tonemap mixed", HDRModeTonemap, []VideoFile{sdr("a"), hdr("b", "smpte2084"), hdr("c", "arib-std-b67")}, false, false, "", []bool{false, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := planHDR(tt.mode, tt.vs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planHDR error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if p.keep != tt.wantKeep || p.transfer != tt.wantTransfer {
+				t.Errorf("keep, transfer = %v, %q, want %v, %q", p.keep, p.transfer, tt.wantKeep, tt.wantTransfer)
+			}
+			if !slices.Equal(p.tonemap, tt.wantTonemap) {
+				t.Errorf("tonemap = %v, want %v", p.tonemap, tt.wantTonemap)
+			}
+			n := 0
+			for _, tm := range tt.wantTonemap {
+				if tm {
+					n++
+				}
+			}
+			if p.tonemapN != n {
+				t.Errorf("tonemapN = %d, want %d", p.tonemapN, n)
+			}
+		})
+	}
+}
+
+func TestPixFmtBitDepth(t *testing.T) {
+	tests := []struct {
+		pixFmt string
+		want   int
+	}{
+		{"yuv420p", 8},
+		{"yuvj420p", 8},
+		{"yuv420p10le", 10},
+		{"yuv422p12be", 12},
+		{"p010le", 10},
+		{"p016le", 16},
+		{"nv12", 8},
+		{"", 8},
+	}
+	for _, tt := range tests {
+		if got := pixFmtBitDepth(tt.pixFmt); got != tt.want {
+			t.Errorf("pixFmtBitDepth(%q) = %d, want %d", tt.pixFmt, got, tt.want)
+		}
+	}
+}
+
+func TestTonemapFilter(t *testing.T) {
+	untagged := testClip("a", func(v *VideoFile) { v.ColorTransfer = "smpte2084"; v.ColorPrimaries = "unknown" })
+	want := "zscale=tin=smpte2084:pin=bt2020:min=bt2020nc:t=linear:npl=100,format=gbrpf32le," +
+		"zscale=p=bt709,tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv"
+	if got := tonemapFilter(untagged); got != want {
+		t.Errorf("tonemapFilter = %q, want %q", got, want)
+	}
+}
+
+func TestClipColor(t *testing.T) {
+	tests := []struct {
+		name string
+		v    VideoFile
+		want colorInfo
+	}{
+		{"tagged HD", testClip("a", func(v *VideoFile) { v.ColorSpace = "bt709"; v.ColorPrimaries = "bt709" }),
+			colorInfo{matrix: "bt709", rng: "tv", primaries: "bt709"}},
+		{"untagged HD", testClip("a", func(v *VideoFile) { v.ColorRange = "" }),
+			colorInfo{matrix: "bt709", rng: "tv", primaries: "bt709"}},
+		{"untagged SD", testClip("a", func(v *VideoFile) { v.Resolution = "720x576"; v.ColorRange = "" }),
+			colorInfo{matrix: "bt601", rng: "tv", primaries: "smpte170m"}},
+		{"untagged portrait SD", testClip("a", func(v *VideoFile) { v.Resolution = "480x854" }),
+			colorInfo{matrix: "bt601", rng: "tv", primaries: "smpte170m"}},
+		{"NTSC tag", testClip("a", func(v *VideoFile) { v.ColorSpace = "smpte170m" }),
+			colorInfo{matrix: "bt601", rng: "tv", primaries: "smpte170m"}},
+		{"PAL tag", testClip("a", func(v *VideoFile) { v.ColorSpace = "bt470bg"; v.ColorPrimaries = "bt470bg" }),
+			colorInfo{matrix: "bt601", rng: "tv", primaries: "bt470bg"}},
+		{"BT.2020", testClip("a", func(v *VideoFile) { v.ColorSpace = "bt2020nc"; v.ColorPrimaries = "unknown" }),
+			colorInfo{matrix: "bt2020", rng: "tv", primaries: "bt2020"}},
+		{"full range tag", testClip("a", func(v *VideoFile) { v.ColorRange = "pc" }),
+			colorInfo{matrix: "bt709", rng: "pc", primaries: "bt709"}},
+		{"yuvj is full range", testClip("a", func(v *VideoFile) { v.PixelFormat = "yuvj420p"; v.ColorRange = "" }),
+			colorInfo{matrix: "bt709", rng: "pc", primaries: "bt709"}},
+		{"tag beats yuvj", testClip("a", func(v *VideoFile) { v.PixelFormat = "yuvj420p"; v.ColorRange = "tv" }),
+			colorInfo{matrix: "bt709", rng: "tv", primaries: "bt709"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clipColor(tt.v); got != tt.want {
+				t.Errorf("clipColor = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColorConvertArgs(t *testing.T) {
+	tests := []struct {
+		in   colorInfo
+		out  string
+		want string
+	}{
+		{colorInfo{matrix: "bt709", rng: "tv"}, "bt709", "in_color_matrix=bt709:out_color_matrix=bt709:in_range=tv:out_range=tv"},
+		{colorInfo{matrix: "bt601", rng: "pc"}, "bt709", "in_color_matrix=bt601:out_color_matrix=bt709:in_range=pc:out_range=tv"},
+		{colorInfo{matrix: "bt709", rng: "tv"}, "bt2020", "in_color_matrix=bt709:out_color_matrix=bt2020:in_range=tv:out_range=tv"},
+	}
+	for _, tt := range tests {
+		if got := colorConvertArgs(tt.in, tt.out); got != tt.want {
+			t.Errorf("colorConvertArgs(%+v, %s) = %q, want %q", tt.in, tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestVideoFilterColor(t *testing.T) {
+	target := normTarget{width: 1920, height: 1080, fps: 30, pixFmt: "yuv420p", outMatrix: "bt709",
+		hdr: hdrPlan{tonemap: []bool{false, true}}}
+	phone := testClip("phone.mp4", func(v *VideoFile) { v.PixelFormat = "yuvj420p"; v.ColorRange = "" })
+	if got := target.videoFilter(0, phone); !strings.Contains(got, "in_color_matrix=bt709:out_color_matrix=bt709:in_range=pc:out_range=tv") {
+		t.Errorf("full range clip filter %q does not convert to limited range", got)
+	}
+
+	// tone mapping already outputs BT.709 limited range
+	hdr := testClip("hdr.mkv", func(v *VideoFile) { v.ColorTransfer = "smpte2084"; v.ColorSpace = "bt2020nc"; v.ColorRange = "pc" })
+	got := target.videoFilter(1, hdr)
+	if !strings.HasPrefix(got, "zscale=tin=smpte2084") {
+		t.Errorf("HDR clip filter %q does not start with tone mapping", got)
+	}
+	if !strings.Contains(got, "in_color_matrix=bt709:out_color_matrix=bt709:in_range=tv:out_range=tv") {
+		t.Errorf("HDR clip filter %q converts from the source colorspace after tone mapping", got)
+	}
+}
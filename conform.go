@@ -0,0 +1,300 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// x264/x265 profile names for the profiles ffprobe reports.
+var encoderProfiles = map[string]map[string]string{
+	"libx264": {
+		"Constrained Baseline":  "baseline",
+		"Baseline":              "baseline",
+		"Main":                  "main",
+		"High":                  "high",
+		"High 10":               "high10",
+		"High 4:2:2":            "high422",
+		"High 4:4:4 Predictive": "high444",
+	},
+	"libx265": {
+		"Main":               "main",
+		"Main 10":            "main10",
+		"Main Still Picture": "mainstillpicture",
+	},
+	"libvpx-vp9": {
+		"Profile 0": "0",
+		"Profile 1": "1",
+		"Profile 2": "2",
+		"Profile 3": "3",
+	},
+}
+
+// audioEncoders maps ffprobe audio codec names to the encoder that writes them.
+var audioEncoders = map[string]string{
+	"aac":       "aac",
+	"opus":      "libopus",
+	"vorbis":    "libvorbis",
+	"mp3":       "libmp3lame",
+	"ac3":       "ac3",
+	"flac":      "flac",
+	"pcm_s16le": "pcm_s16le",
+}
+
+// conformPlan describes a merge that re-encodes only the deviating clips to
+// the parameters most clips share.
+type conformPlan struct {
+	ref      VideoFile // a compliant clip whose parameters the others are matched to
+	codec    OutputCodec
+	encoder  string
+	audioEnc string
+	deviant  []bool
+	n        int // number of deviating clips
+
+	quality   int
+	keyframes KeyframeOptions
+}
+
+// planConform picks the reference clip and checks its parameters can be
+// reproduced exactly; otherwise the caller falls back to full normalization.
+func (a *App) planConform(vs []VideoFile, reasons []CopyIncompatibility, outputFile string, o mergeOptions) (conformPlan, error) {
+	p := conformPlan{deviant: make([]bool, len(vs)), quality: o.quality, keyframes: o.keyframes}
+	for _, r := range reasons {
+		if !p.deviant[r.Clip-1] {
+			p.deviant[r.Clip-1] = true
+			p.n++
+		}
+	}
+	if p.n == 0 {
+		return p, fmt.Errorf("no clip deviates from the others")
+	}
+	ref := slices.IndexFunc(p.deviant, func(d bool) bool { return !d })
+	if ref < 0 {
+		return p, fmt.Errorf("no clip matches the majority on every parameter")
+	}
+	p.ref = vs[ref]
+
+	// Parameters the conforming filter chain can't reproduce bit-exactly
+	switch {
+	case isHDR(p.ref):
+		return p, fmt.Errorf("the majority format is HDR")
+	case p.ref.Rotation != 0:
+		return p, fmt.Errorf("the majority clips carry a rotation tag")
+	case normalizeSAR(p.ref.SAR) != "1:1":
+		return p, fmt.Errorf("the majority clips use non-square pixels (%s)", p.ref.SAR)
+	case normalizeFieldOrder(p.ref.FieldOrder) != "progressive" && normalizeFieldOrder(p.ref.FieldOrder) != "unknown":
+		return p, fmt.Errorf("the majority clips are interlaced")
+	case clipColor(p.ref).rng == "pc":
+		return p, fmt.Errorf("the majority clips are full range")
+	}
+	for i, v := range vs {
+		if p.deviant[i] && isHDR(v) {
+			return p, fmt.Errorf("%s is HDR and would need tone mapping", v.FileName)
+		}
+	}
+
+	p.codec = OutputCodec(p.ref.Codec)
+	sw, ok := swEncoders[p.codec]
+	if !ok {
+		return p, fmt.Errorf("no encoder for %s", p.ref.Codec)
+	}
+	for _, name := range sw {
+		if a.encoders()[name] {
+			p.encoder = name
+			break
+		}
+	}
+	if p.encoder == "" {
+		return p, fmt.Errorf("no usable %s software encoder", strings.ToUpper(p.ref.Codec))
+	}
+	if err := checkContainer(p.codec, outputFile); err != nil {
+		return p, err
+	}
+	if p.ref.HasAudio {
+		p.audioEnc = audioEncoders[p.ref.AudioCodec]
+		if p.audioEnc == "" {
+			return p, fmt.Errorf("no encoder for %s audio", p.ref.AudioCodec)
+		}
+	}
+	return p, nil
+}
+
+// requirements lists the features the conforming encodes use.
+func (p conformPlan) requirements(vs []VideoFile) jobRequirements {
+	req := jobRequirements{
+		encoders: []string{p.encoder},
+		filters:  []string{"scale", "pad", "setsar", "fps", "format"},
+	}
+	if p.audioEnc != "" {
+		req.encoders = append(req.encoders, p.audioEnc)
+		req.filters = append(req.filters, "aformat", "anullsrc")
+	}
+	for i, v := range vs {
+		if p.deviant[i] && !slices.Contains(req.decoders, v.Codec) {
+			req.decoders = append(req.decoders, v.Codec)
+		}
+	}
+	return req
+}
+
+// frameRate returns the reference rate as ffmpeg's fps filter expects it.
+func (p conformPlan) frameRate() string {
+	if r := p.ref.FrameRate; r != "" && r != "0/0" {
+		return r
+	}
+	return strconv.FormatFloat(p.ref.FPS, 'f', -1, 64)
+}
+
+// encoderArgs returns the video encoder options that reproduce the
+// reference's profile, level and pixel format. Parameter sets are repeated
+// in-band so decoders pick up the new SPS/PPS where a conformed clip starts.
+func (p conformPlan) encoderArgs(quality int, k KeyframeOptions) []string {
+	args := encoderArgs(p.encoder, encodeSpec{codec: p.codec, quality: quality, rc: RateControl{Mode: RateQuality}, keyframes: k})
+	// encoderArgs picks a generic pixel format; use the reference's instead
+	if i := slices.Index(args, "-pix_fmt"); i >= 0 {
+		args[i+1] = p.ref.PixelFormat
+	} else {
+		args = append(args, "-pix_fmt", p.ref.PixelFormat)
+	}
+	if profile, ok := encoderProfiles[p.encoder][p.ref.Profile]; ok {
+		args = append(args, "-profile:v", profile)
+	}
+	if p.ref.Level > 0 {
+		switch p.encoder {
+		case "libx264":
+			args = append(args, "-level:v", fmt.Sprintf("%d.%d", p.ref.Level/10, p.ref.Level%10))
+		case "libx265":
+			// ffprobe reports HEVC levels as 30 x the level number
+			args = append(args, "-x265-params", "level-idc="+strconv.FormatFloat(float64(p.ref.Level)/30, 'f', 1, 64))
+		}
+	}
+	switch p.encoder {
+	case "libx264":
+		args = append(args, "-x264-params", "repeat-headers=1")
+	case "libx265":
+		args = append(args, "-x265-params", "repeat-headers=1")
+	}
+	return coalesceParams(args)
+}
+
+// colorTags signals the reference's color description on the output.
+func (p conformPlan) colorTags() []string {
+	var args []string
+	tag := func(opt, value string) {
+		if value != "" && value != "unknown" {
+			args = append(args, opt, value)
+		}
+	}
+	tag("-color_primaries", p.ref.ColorPrimaries)
+	tag("-color_trc", p.ref.ColorTransfer)
+	tag("-colorspace", p.ref.ColorSpace)
+	return append(args, "-color_range", "tv")
+}
+
+// conformArgs builds the ffmpeg command that converts a deviating clip.
+func (a *App) conformArgs(p conformPlan, v VideoFile, output string) []string {
+	var w, h int
+	fmt.Sscanf(p.ref.Resolution, "%dx%d", &w, &h)
+	args := append([]string{"-y", "-hide_banner", "-loglevel", "error"}, v.inputArgs()...)
+	silence := p.ref.HasAudio && !v.HasAudio
+	if silence {
+		args = append(args, "-f", "lavfi", "-t", strconv.FormatFloat(v.Duration, 'f', 3, 64),
+			"-i", fmt.Sprintf("anullsrc=channel_layout=%s:sample_rate=%d", cmp.Or(p.ref.ChannelLayout, "stereo"), p.ref.SampleRate))
+	}
+	vf := fmt.Sprintf(
+		"scale=%d:%d:force_original_aspect_ratio=decrease:%s,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%s,format=%s",
+		w, h, colorConvertArgs(clipColor(v), clipColor(p.ref).matrix), w, h, p.frameRate(), p.ref.PixelFormat)
+	args = append(args, "-vf", vf, "-map", "0:v:0", "-sn", "-dn", "-map_metadata", "-1", "-map_chapters", "-1",
+		"-metadata:s:v:0", "rotate=0")
+	args = append(args, p.encoderArgs(p.quality, p.keyframes)...)
+	args = append(args, p.colorTags()...)
+
+	// MP4/MOV store the time base per track; match it so timestamps line up
+	if c := outputContainer(output); c == "mp4" || c == "mov" {
+		if _, den, ok := strings.Cut(p.ref.TimeBase, "/"); ok {
+			args = append(args, "-video_track_timescale", den)
+		}
+	}
+
+	switch {
+	case !p.ref.HasAudio:
+		args = append(args, "-an")
+	case silence:
+		args = append(args, "-map", "1:a:0", "-shortest")
+	default:
+		args = append(args, "-map", "0:a:0")
+	}
+	if p.ref.HasAudio {
+		args = append(args, "-c:a", p.audioEnc, "-ar", strconv.Itoa(p.ref.SampleRate))
+		if p.ref.ChannelLayout != "" {
+			args = append(args, "-af", "aformat=channel_layouts="+p.ref.ChannelLayout)
+		}
+	}
+	args = append(args, codecTagArgs(p.ref.Codec, output)...)
+	return append(args, output)
+}
+
+// conformMerge re-encodes the deviating clips to the reference parameters
+// and joins them with the untouched clips by stream copy. Progress runs over
+// the deviating clips' duration and then the final join.
+func (a *App) conformMerge(ctx context.Context, vs []VideoFile, p conformPlan, outputFile string) (string, error) {
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": fmt.Sprintf("Conforming %d of %d clip(s) to %s %s %s with %s; copying the rest", p.n, len(vs), p.ref.Resolution, strings.ToUpper(p.ref.Codec), p.frameRate(), p.encoder),
+	})
+
+	tempDir, err := os.MkdirTemp(a.tempDir(), "stitcher-conform-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir for conforming: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var deviantDuration float64
+	for i, v := range vs {
+		if p.deviant[i] {
+			deviantDuration += v.Duration
+		}
+	}
+	// The conforming encodes take most of the time; the join is a copy
+	const encodeShare = 90.0
+
+	// Untouched clips keep their out points; conformed ones are already cut
+	clips := make([]VideoFile, len(vs))
+	var done float64
+	for i, v := range vs {
+		if !p.deviant[i] {
+			clips[i] = v
+			continue
+		}
+		out := filepath.Join(tempDir, fmt.Sprintf("conformed-%d%s", i, filepath.Ext(outputFile)))
+		from := encodeShare * done / deviantDuration
+		to := encodeShare * (done + v.Duration) / deviantDuration
+		if err := a.runFFmpegProgress(ctx, a.conformArgs(p, v, out), v.Duration, fmt.Sprintf("Conforming %s...", v.FileName), from, to); err != nil {
+			return "", fmt.Errorf("failed to conform %s: %w", v.FileName, err)
+		}
+		done += v.Duration
+		clips[i] = VideoFile{Path: out}
+	}
+
+	listFile, err := writeClipConcatList(clips)
+	if err != nil {
+		return "", fmt.Errorf("failed to write concat list: %w", err)
+	}
+	defer os.Remove(listFile)
+
+	// Map explicitly so camera data tracks on the untouched clips don't
+	// make the stream layout differ from the conformed ones
+	args := []string{"-y", "-f", "concat", "-safe", "0", "-i", listFile, "-map", "0:v:0", "-map", "0:a:0?", "-c", "copy"}
+	args = append(args, codecTagArgs(p.ref.Codec, outputFile)...)
+	args = append(args, outputFile)
+	if err := a.runFFmpegProgress(ctx, args, totalDuration(vs), "Merging...", encodeShare, 100); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Successfully merged videos to %s (%d of %d clips re-encoded)", outputFile, p.n, len(vs)), nil
+}
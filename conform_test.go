@@ -0,0 +1,102 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPlanConform(t *testing.T) {
+	a := &App{}
+	a.setEncoders(map[string]bool{"libx264": true, "libx265": true})
+	phone := func(v *VideoFile) { v.Resolution = "1280x720"; v.Profile = "Main" }
+	tests := []struct {
+		name        string
+		vs          []VideoFile
+		output      string
+		wantRef     string
+		wantDeviant []bool
+		wantEncoder string
+		wantErr     string // substring; "" means success
+	}{
+		{"one deviant", []VideoFile{testClip("a"), testClip("b", phone), testClip("c")}, "out.mp4",
+			"a", []bool{false, true, false}, "libx264", ""},
+		{"majority wins over the first clip", []VideoFile{testClip("a", phone), testClip("b"), testClip("c")}, "out.mp4",
+			"b", []bool{true, false, false}, "libx264", ""},
+		{"hevc majority", []VideoFile{
+			testClip("a", func(v *VideoFile) { v.Codec = "hevc"; v.Profile = "Main" }),
+			testClip("b", func(v *VideoFile) { v.Codec = "hevc"; v.Profile = "Main" }),
+			testClip("c"),
+		}, "out.mkv", "a", []bool{false, false, true}, "libx265", ""},
+		{"nothing to conform", []VideoFile{testClip("a"), testClip("b")}, "out.mp4", "", nil, "", "no clip deviates"},
+		{"majority full range", []VideoFile{
+			testClip("a", func(v *VideoFile) { v.ColorRange = "pc" }),
+			testClip("b", func(v *VideoFile) { v.ColorRange = "pc" }),
+			testClip("c"),
+		}, "out.mp4", "", nil, "", "full range"},
+		{"majority rotated", []VideoFile{
+			testClip("a", func(v *VideoFile) { v.Rotation = 90 }),
+			testClip("b", func(v *VideoFile) { v.Rotation = 90 }),
+			testClip("c"),
+		}, "out.mp4", "", nil, "", "rotation"},
+		{"deviant HDR", []VideoFile{testClip("a"), testClip("b"), testClip("c", func(v *VideoFile) { v.ColorTransfer = "smpte2084" })},
+			"out.mp4", "", nil, "", "tone mapping"},
+		{"no encoder", []VideoFile{
+			testClip("a", func(v *VideoFile) { v.Codec = "vp9" }),
+			testClip("b", func(v *VideoFile) { v.Codec = "vp9" }),
+			testClip("c"),
+		}, "out.webm", "", nil, "", "software encoder"},
+		{"container", []VideoFile{
+			testClip("a", func(v *VideoFile) { v.Codec = "hevc" }),
+			testClip("b", func(v *VideoFile) { v.Codec = "hevc" }),
+			testClip("c"),
+		}, "out.webm", "", nil, "", "can't be stored"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.planConform(tt.vs, copyIncompatibilities(tt.vs), tt.output, mergeOptions{quality: DefaultQuality})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("planConform error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planConform: %v", err)
+			}
+			if p.ref.FileName != tt.wantRef || !slices.Equal(p.deviant, tt.wantDeviant) || p.encoder != tt.wantEncoder {
+				t.Errorf("planConform = ref %s, deviant %v, encoder %s, want %s, %v, %s",
+					p.ref.FileName, p.deviant, p.encoder, tt.wantRef, tt.wantDeviant, tt.wantEncoder)
+			}
+		})
+	}
+}
+
+func TestConformEncoderArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		p    conformPlan
+		want []string // must appear in order
+	}{
+		{"x264", conformPlan{ref: testClip("a"), codec: CodecH264, encoder: "libx264"},
+			[]string{"-pix_fmt", "yuv420p", "-profile:v", "high", "-level:v", "4.1", "-x264-params", "repeat-headers=1"}},
+		{"x264 baseline", conformPlan{ref: testClip("a", func(v *VideoFile) { v.Profile = "Constrained Baseline"; v.Level = 31 }), codec: CodecH264, encoder: "libx264"},
+			[]string{"-profile:v", "baseline", "-level:v", "3.1"}},
+		{"x265", conformPlan{ref: testClip("a", func(v *VideoFile) {
+			v.Codec = "hevc"
+			v.Profile = "Main 10"
+			v.Level = 150
+			v.PixelFormat = "yuv420p10le"
+		}), codec: CodecHEVC, encoder: "libx265"},
+			[]string{"-pix_fmt", "yuv420p10le", "-profile:v", "main10", "-x265-params", "level-idc=5.0:repeat-headers=1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.p.encoderArgs(DefaultQuality, DefaultKeyframeOptions)
+			i := slices.Index(got, tt.want[0])
+			if i < 0 || len(got) < i+len(tt.want) || !slices.Equal(got[i:i+len(tt.want)], tt.want) {
+				t.Errorf("encoderArgs = %q, want %q in order", got, tt.want)
+			}
+		})
+	}
+}
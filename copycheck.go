@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CopyIncompatibility explains why one clip can't be stream-copied together
+// with the rest of the timeline.
+type CopyIncompatibility struct {
+	Clip     int    `json:"clip"` // 1-based position in the timeline
+	FileName string `json:"fileName"`
+	Property string `json:"property"` // e.g. "audio codec"
+	Value    string `json:"value"`    // this clip's value
+	Expected string `json:"expected"` // the value most clips share
+	Message  string `json:"message"`  // e.g. "clip 3 (b.mp4): audio codec is opus, others aac"
+}
+
+// copyProperty is one stream parameter the concat demuxer needs to match.
+type copyProperty struct {
+	name string
+	// applies limits the comparison to some clips (e.g. those with audio)
+	applies func(v VideoFile) bool
+	value   func(v VideoFile) string
+	// same compares two values; nil means string equality
+	same func(a, b VideoFile) bool
+}
+
+func clipHasAudio(v VideoFile) bool { return v.HasAudio }
+
+// copyProperties lists everything that has to agree for concat -c copy to
+// produce a valid file. The demuxer writes the first clip's parameters into
+// the output header, so any difference here yields a broken or rejected file.
+var copyProperties = []copyProperty{
+	{name: "video codec", value: func(v VideoFile) string { return v.Codec }},
+	{name: "profile", value: func(v VideoFile) string { return v.Profile }},
+	{name: "level", value: func(v VideoFile) string { return formatLevel(v.Level) }},
+	{name: "resolution", value: func(v VideoFile) string { return v.Resolution }},
+	// The concat demuxer keeps only the first clip's display matrix, so
+	// clips stored with different rotations can't be stream-copied together
+	{name: "rotation", value: func(v VideoFile) string { return fmt.Sprintf("%d°", v.Rotation) }},
+	{name: "sample aspect ratio", value: func(v VideoFile) string { return normalizeSAR(v.SAR) }},
+	{name: "field order", value: func(v VideoFile) string { return normalizeFieldOrder(v.FieldOrder) }},
+	{name: "time base", value: func(v VideoFile) string { return v.TimeBase }},
+	// Allow small FPS rounding differences (e.g., 29.97 vs 29.9701)
+	{
+		name:  "frame rate",
+		value: func(v VideoFile) string { return strconv.FormatFloat(v.FPS, 'f', 3, 64) },
+		same:  func(a, b VideoFile) bool { return math.Abs(a.FPS-b.FPS) <= 0.05 },
+	},
+	{name: "pixel format", value: func(v VideoFile) string { return v.PixelFormat }},
+	// Joining HDR and SDR streams without conversion gives wrong colors on one side
+	{name: "dynamic range", value: func(v VideoFile) string {
+		if isHDR(v) {
+			return "HDR"
+		}
+		return "SDR"
+	}},
+	// Differing matrix, range or primaries show up as color/brightness
+	// shifts at the joins, so they need a conversion pass
+	{name: "color", value: func(v VideoFile) string {
+		c := clipColor(v)
+		return fmt.Sprintf("%s/%s/%s", c.matrix, c.primaries, c.rng)
+	}},
+	// Differing SPS/PPS or codec configuration makes the decoder misread every
+	// clip after the first; unknown hashes (older ffprobe) aren't compared
+	{
+		name:  "codec configuration",
+		value: func(v VideoFile) string { return v.ExtradataHash },
+		same: func(a, b VideoFile) bool {
+			return a.ExtradataHash == "" || b.ExtradataHash == "" || a.ExtradataHash == b.ExtradataHash
+		},
+	},
+	{name: "audio", value: func(v VideoFile) string {
+		if v.HasAudio {
+			return "present"
+		}
+		return "none"
+	}},
+	{name: "audio codec", applies: clipHasAudio, value: func(v VideoFile) string { return v.AudioCodec }},
+	{name: "sample rate", applies: clipHasAudio, value: func(v VideoFile) string { return fmt.Sprintf("%d Hz", v.SampleRate) }},
+	{name: "channel layout", applies: clipHasAudio, value: func(v VideoFile) string { return v.ChannelLayout }},
+}
+
+// formatLevel renders ffprobe's level number; -99 means unknown.
+func formatLevel(level int) string {
+	if level <= 0 {
+		return "unknown"
+	}
+	return strconv.Itoa(level)
+}
+
+// normalizeSAR treats a missing or 0:1 (unset) aspect ratio as square pixels.
+func normalizeSAR(sar string) string {
+	if sar == "" || sar == "0:1" || sar == "N/A" {
+		return "1:1"
+	}
+	return sar
+}
+
+func normalizeFieldOrder(order string) string {
+	if order == "" {
+		return "unknown"
+	}
+	return order
+}
+
+// majorityClass groups the clips a property applies to into classes of
+// matching values and returns a representative of the largest class. Ties go
+// to the class that appears first in the timeline.
+func majorityClass(vs []VideoFile, p copyProperty) (VideoFile, bool) {
+	same := p.same
+	if same == nil {
+		same = func(a, b VideoFile) bool { return p.value(a) == p.value(b) }
+	}
+	var reps []VideoFile
+	var counts []int
+	for _, v := range vs {
+		if p.applies != nil && !p.applies(v) {
+			continue
+		}
+		matched := false
+		for i, r := range reps {
+			if same(r, v) {
+				counts[i]++
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			reps = append(reps, v)
+			counts = append(counts, 1)
+		}
+	}
+	if len(reps) == 0 {
+		return VideoFile{}, false
+	}
+	best := 0
+	for i := range counts {
+		if counts[i] > counts[best] {
+			best = i
+		}
+	}
+	return reps[best], true
+}
+
+// copyIncompatibilities compares every clip against the value most clips
+// share for each stream parameter and reports each deviation.
+func copyIncompatibilities(vs []VideoFile) []CopyIncompatibility {
+	var out []CopyIncompatibility
+	for _, p := range copyProperties {
+		ref, ok := majorityClass(vs, p)
+		if !ok {
+			continue
+		}
+		same := p.same
+		if same == nil {
+			same = func(a, b VideoFile) bool { return p.value(a) == p.value(b) }
+		}
+		for i, v := range vs {
+			if p.applies != nil && !p.applies(v) {
+				continue
+			}
+			if same(ref, v) {
+				continue
+			}
+			value, expected := p.value(v), p.value(ref)
+			out = append(out, CopyIncompatibility{
+				Clip:     i + 1,
+				FileName: v.FileName,
+				Property: p.name,
+				Value:    value,
+				Expected: expected,
+				Message:  fmt.Sprintf("clip %d (%s): %s is %s, others %s", i+1, v.FileName, p.name, value, expected),
+			})
+		}
+	}
+	return out
+}
+
+// CheckFastMerge returns why the clips can't be joined by stream copy; an
+// empty list means a fast merge should work.
+func (a *App) CheckFastMerge(videoFiles []VideoFile) []CopyIncompatibility {
+	return copyIncompatibilities(videoFiles)
+}
+
+// summarizeIncompatibilities joins the first few reasons for the job log.
+func summarizeIncompatibilities(reasons []CopyIncompatibility, limit int) string {
+	msgs := make([]string, 0, limit)
+	for i, r := range reasons {
+		if i == limit {
+			msgs = append(msgs, fmt.Sprintf("and %d more", len(reasons)-limit))
+			break
+		}
+		msgs = append(msgs, r.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
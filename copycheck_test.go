@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCopyIncompatibilities(t *testing.T) {
+	type want struct {
+		clip     int
+		property string
+		value    string
+		expected string
+	}
+	tests := []struct {
+		name string
+		vs   []VideoFile
+		want []want
+	}{
+		{"identical", []VideoFile{testClip("a"), testClip("b"), testClip("c")}, nil},
+		{"profile", []VideoFile{testClip("a"), testClip("b", func(v *VideoFile) { v.Profile = "Main" }), testClip("c")},
+			[]want{{2, "profile", "Main", "High"}}},
+		{"level", []VideoFile{testClip("a"), testClip("b"), testClip("c", func(v *VideoFile) { v.Level = 51 })},
+			[]want{{3, "level", "51", "41"}}},
+		{"unset SAR is square", []VideoFile{testClip("a"), testClip("b", func(v *VideoFile) { v.SAR = "0:1" }), testClip("c", func(v *VideoFile) { v.SAR = "" })}, nil},
+		{"anamorphic", []VideoFile{testClip("a"), testClip("b", func(v *VideoFile) { v.SAR = "4:3" })},
+			[]want{{2, "sample aspect ratio", "4:3", "1:1"}}},
+		{"field order", []VideoFile{testClip("a", func(v *VideoFile) { v.FieldOrder = "tt" }), testClip("b"), testClip("c")},
+			[]want{{1, "field order", "tt", "progressive"}}},
+		{"time base", []VideoFile{testClip("a"), testClip("b", func(v *VideoFile) { v.TimeBase = "1/30000" })},
+			[]want{{2, "time base", "1/30000", "1/15360"}}},
+		{"frame rate rounding", []VideoFile{testClip("a", func(v *VideoFile) { v.FPS = 29.97 }), testClip("b", func(v *VideoFile) { v.FPS = 29.9701 })}, nil},
+		{"extradata", []VideoFile{
+			testClip("a", func(v *VideoFile) { v.ExtradataHash = "CRC32:aaaa" }),
+			testClip("b", func(v *VideoFile) { v.ExtradataHash = "CRC32:aaaa" }),
+			testClip("c", func(v *VideoFile) { v.ExtradataHash = "CRC32:bbbb" }),
+		}, []want{{3, "codec configuration", "CRC32:bbbb", "CRC32:aaaa"}}},
+		{"unknown extradata", []VideoFile{testClip("a", func(v *VideoFile) { v.ExtradataHash = "CRC32:aaaa" }), testClip("b")}, nil},
+		{"audio codec", []VideoFile{testClip("a"), testClip("b", func(v *VideoFile) { v.AudioCodec = "opus" }), testClip("c")},
+			[]want{{2, "audio codec", "opus", "aac"}}},
+		{"silent clip", []VideoFile{testClip("a"), testClip("b", func(v *VideoFile) { v.HasAudio = false; v.AudioCodec = "" })},
+			[]want{{2, "audio", "none", "present"}}},
+		{"tie goes to the first clip", []VideoFile{testClip("a", func(v *VideoFile) { v.Profile = "Main" }), testClip("b")},
+			[]want{{2, "profile", "High", "Main"}}},
+		{"full range", []VideoFile{testClip("a"), testClip("b", func(v *VideoFile) { v.ColorRange = "pc" })},
+			[]want{{2, "color", "bt709/bt709/pc", "bt709/bt709/tv"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []want
+			for _, r := range copyIncompatibilities(tt.vs) {
+				got = append(got, want{r.Clip, r.Property, r.Value, r.Expected})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("copyIncompatibilities = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyIncompatibilityMessage(t *testing.T) {
+	vs := []VideoFile{testClip("a.mp4"), testClip("b.mp4", func(v *VideoFile) { v.AudioCodec = "opus" })}
+	got := copyIncompatibilities(vs)
+	if len(got) != 1 {
+		t.Fatalf("copyIncompatibilities = %+v, want one reason", got)
+	}
+	if want := "clip 2 (b.mp4): audio codec is opus, others aac"; got[0].Message != want {
+		t.Errorf("message = %q, want %q", got[0].Message, want)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// EdgeTrim is a proposed trim of the black or silent stretches at a clip's
+// start and end.
+type EdgeTrim struct {
+	Clip      int     `json:"clip"` // 1-based position in the timeline
+	FileName  string  `json:"fileName"`
+	TrimStart float64 `json:"trimStart"`
+	TrimEnd   float64 `json:"trimEnd"`
+	Reason    string  `json:"reason"` // e.g. "black start, silent end"
+}
+
+const (
+	// edgeWindow is how far into each end of a clip (seconds) black and
+	// silence are looked for, and so the longest stretch trimmed.
+	edgeWindow = 30.0
+	// minEdgeKeep is the shortest clip an edge trim may leave.
+	minEdgeKeep = 1.0
+	// edgeSlack absorbs frame and audio packet granularity when deciding
+	// whether a stretch touches the edge of the analyzed window.
+	edgeSlack = 0.25
+
+	blackDetectFilter   = "blackdetect=d=0.1:pix_th=0.10"
+	silenceDetectFilter = "silencedetect=noise=-50dB:d=0.5"
+)
+
+var (
+	blackRe        = regexp.MustCompile(`black_start:\s*([\d.]+)\s+black_end:\s*([\d.]+)`)
+	silenceStartRe = regexp.MustCompile(`silence_start:\s*(-?[\d.]+)`)
+	silenceEndRe   = regexp.MustCompile(`silence_end:\s*([\d.]+)`)
+)
+
+// interval is a stretch in seconds from the start of the analyzed window.
+type interval struct{ start, end float64 }
+
+// edgeStretches reports the black and silent stretches blackdetect and
+// silencedetect log for a stretch of the clip. A silence still running at
+// the end has no silence_end and is closed at dur.
+func edgeStretches(output string, dur float64) (black, silence []interval) {
+	for _, m := range blackRe.FindAllStringSubmatch(output, -1) {
+		s, _ := strconv.ParseFloat(m[1], 64)
+		e, _ := strconv.ParseFloat(m[2], 64)
+		black = append(black, interval{s, e})
+	}
+	// silence_start can be slightly negative at the very beginning
+	var start float64
+	open := false
+	for _, line := range strings.Split(output, "\n") {
+		if m := silenceStartRe.FindStringSubmatch(line); m != nil {
+			start, _ = strconv.ParseFloat(m[1], 64)
+			open = true
+		} else if m := silenceEndRe.FindStringSubmatch(line); m != nil && open {
+			e, _ := strconv.ParseFloat(m[1], 64)
+			silence = append(silence, interval{max(0, start), e})
+			open = false
+		}
+	}
+	if open {
+		silence = append(silence, interval{max(0, start), dur})
+	}
+	return black, silence
+}
+
+// leading returns how long the stretch starting the window lasts, 0 if none.
+// A stretch that fills the whole window isn't counted: where it ends, and so
+// whether the clip is dead footage or just dark or quiet, isn't known.
+func leading(stretches []interval, dur float64) float64 {
+	for _, s := range stretches {
+		if s.start <= edgeSlack {
+			if s.end >= dur-edgeSlack {
+				return 0
+			}
+			return s.end
+		}
+	}
+	return 0
+}
+
+// trailing returns how long the stretch ending the window lasts, 0 if none
+// or if it fills the whole window.
+func trailing(stretches []interval, dur float64) float64 {
+	for i := len(stretches) - 1; i >= 0; i-- {
+		if stretches[i].end >= dur-edgeSlack {
+			if stretches[i].start <= edgeSlack {
+				return 0
+			}
+			return dur - stretches[i].start
+		}
+	}
+	return 0
+}
+
+// edgeAnalysis is what blackdetect and silencedetect found at one end of a
+// clip.
+type edgeAnalysis struct {
+	black, silence []interval
+	dur            float64 // seconds analyzed
+	audio          bool    // silence was looked for
+}
+
+// analyzeEdge runs blackdetect, and silencedetect when the clip has sound,
+// over one end of the clip: its first edgeWindow seconds, or its last when
+// tail is set.
+func analyzeEdge(ctx context.Context, v VideoFile, tail, withSilence bool) (edgeAnalysis, error) {
+	e := edgeAnalysis{dur: min(edgeWindow, v.Duration), audio: v.HasAudio && withSilence}
+	start := 0.0
+	if tail {
+		start = v.Duration - e.dur
+	}
+	args := []string{"-hide_banner", "-nostats", "-loglevel", "info", "-ss", formatSeconds(start), "-t", formatSeconds(e.dur), "-i", v.Path,
+		"-map", "0:v:0", "-vf", blackDetectFilter}
+	if e.audio {
+		args = append(args, "-map", "0:a:0", "-af", silenceDetectFilter)
+	}
+	out, err := exec.CommandContext(ctx, ffmpegBin(), append(args, "-f", "null", "-")...).CombinedOutput()
+	if err != nil {
+		return e, fmt.Errorf("failed to analyze %s: %w\nffmpeg: %s", v.FileName, err, out)
+	}
+	e.black, e.silence = edgeStretches(string(out), e.dur)
+	return e, nil
+}
+
+// deadEdge returns how much of one end of a clip is dead footage: the part
+// that is black and/or silent, as selected. With both selected it must be
+// both, so silence never removes frames that are visible.
+func deadEdge(e edgeAnalysis, tail, trimBlack, trimSilence bool) (cut float64, reason string) {
+	edge := func(s []interval) float64 { return leading(s, e.dur) }
+	side := "start"
+	if tail {
+		edge = func(s []interval) float64 { return trailing(s, e.dur) }
+		side = "end"
+	}
+	var kinds []string
+	cut = math.Inf(1)
+	if trimBlack {
+		cut = min(cut, edge(e.black))
+		kinds = append(kinds, "black")
+	}
+	if trimSilence && e.audio {
+		cut = min(cut, edge(e.silence))
+		kinds = append(kinds, "silent")
+	}
+	if len(kinds) == 0 || cut <= 0 {
+		return 0, ""
+	}
+	return cut, strings.Join(kinds, " and ") + " " + side
+}
+
+// planEdgeTrim turns the analysis of both ends of a clip into a trim. A
+// trim that would leave less than minEdgeKeep isn't proposed: the clip may
+// be meant to be dark or quiet.
+func planEdgeTrim(v VideoFile, head, tail edgeAnalysis, trimBlack, trimSilence bool) EdgeTrim {
+	t := EdgeTrim{FileName: v.FileName}
+	var reasons []string
+	var why string
+	if t.TrimStart, why = deadEdge(head, false, trimBlack, trimSilence); why != "" {
+		reasons = append(reasons, why)
+	}
+	if t.TrimEnd, why = deadEdge(tail, true, trimBlack, trimSilence); why != "" {
+		reasons = append(reasons, why)
+	}
+	if v.Duration-t.TrimStart-t.TrimEnd < minEdgeKeep {
+		return EdgeTrim{FileName: v.FileName}
+	}
+	t.Reason = strings.Join(reasons, ", ")
+	return t
+}
+
+// proposeEdgeTrim finds the black and/or silent stretches at both ends of a
+// clip.
+func proposeEdgeTrim(ctx context.Context, v VideoFile, trimBlack, trimSilence bool) (EdgeTrim, error) {
+	head, err := analyzeEdge(ctx, v, false, trimSilence)
+	if err != nil {
+		return EdgeTrim{FileName: v.FileName}, err
+	}
+	tail, err := analyzeEdge(ctx, v, true, trimSilence)
+	if err != nil {
+		return EdgeTrim{FileName: v.FileName}, err
+	}
+	return planEdgeTrim(v, head, tail, trimBlack, trimSilence), nil
+}
+
+// findEdgeTrims proposes edge trims for every clip and reports each one in
+// the job log. Clips that can't be analyzed are left untrimmed.
+func (a *App) findEdgeTrims(ctx context.Context, vs []VideoFile, trimBlack, trimSilence bool) ([]EdgeTrim, error) {
+	var trims []EdgeTrim
+	for i, v := range vs {
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("Looking for black frames and silence in %s (%d of %d)...", v.FileName, i+1, len(vs)),
+		})
+		t, err := proposeEdgeTrim(ctx, v, trimBlack, trimSilence)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("merge cancelled by user")
+		}
+		if err != nil {
+			log.Printf("[edges] %v", err)
+			continue
+		}
+		if t.TrimStart == 0 && t.TrimEnd == 0 {
+			continue
+		}
+		t.Clip = i + 1
+		trims = append(trims, t)
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("%s: trimming %.2f s from the start and %.2f s from the end (%s)", v.FileName, t.TrimStart, t.TrimEnd, t.Reason),
+		})
+	}
+	return trims, nil
+}
+
+// applyEdgeTrims widens the clips' trims to the proposed ones; trims the
+// user set further in are kept.
+func applyEdgeTrims(vs []VideoFile, trims []EdgeTrim) []VideoFile {
+	out := make([]VideoFile, len(vs))
+	copy(out, vs)
+	for _, t := range trims {
+		v := &out[t.Clip-1]
+		v.TrimStart = max(v.TrimStart, t.TrimStart)
+		v.TrimEnd = max(v.TrimEnd, t.TrimEnd)
+	}
+	return out
+}
+
+// DetectEdgeTrims proposes trims of the black and silent stretches at the
+// edges of each clip, without changing the clips. Both detectors run
+// regardless of the merge settings.
+func (a *App) DetectEdgeTrims(videoFiles []VideoFile) ([]EdgeTrim, error) {
+	if a.bins.FFmpegError != "" {
+		return nil, fmt.Errorf("%s", a.bins.FFmpegError)
+	}
+	return a.findEdgeTrims(a.ctx, videoFiles, true, true)
+}
+
+// SetEdgeTrimming selects whether merges trim black frames and silence at
+// clip edges; with both selected only footage that is black and silent is
+// trimmed. Trimming a clip's start means it's re-encoded.
+func (a *App) SetEdgeTrimming(black, silence bool) {
+	a.settings.TrimBlack = black
+	a.settings.TrimSilence = silence
+	a.persistSettings()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLeadingTrailing(t *testing.T) {
+	tests := []struct {
+		name              string
+		stretches         []interval
+		dur               float64
+		leading, trailing float64
+	}{
+		{"none", nil, 30, 0, 0},
+		{"black start", []interval{{0, 2.5}}, 30, 2.5, 0},
+		{"starts just after the window start", []interval{{0.1, 2}}, 30, 2, 0},
+		{"black end", []interval{{27, 30}}, 30, 0, 3},
+		{"both ends", []interval{{0, 1}, {10, 11}, {29, 30}}, 30, 1, 1},
+		{"in the middle only", []interval{{10, 12}}, 30, 0, 0},
+		{"fills the window", []interval{{0, 30}}, 30, 0, 0},
+		{"fills the window within slack", []interval{{0.1, 29.9}}, 30, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := leading(tt.stretches, tt.dur); got != tt.leading {
+				t.Errorf("leading = %v, want %v", got, tt.leading)
+			}
+			if got := trailing(tt.stretches, tt.dur); got != tt.trailing {
+				t.Errorf("trailing = %v, want %v", got, tt.trailing)
+			}
+		})
+	}
+}
+
+func TestPlanEdgeTrim(t *testing.T) {
+	edge := func(dur float64, black []interval, silence ...interval) edgeAnalysis {
+		return edgeAnalysis{black: black, silence: silence, dur: dur, audio: true}
+	}
+	long := testClip("long.mp4", func(v *VideoFile) { v.Duration = 600 })
+	short := testClip("short.mp4", func(v *VideoFile) { v.Duration = 10 })
+	tests := []struct {
+		name               string
+		v                  VideoFile
+		head, tail         edgeAnalysis
+		black, silence     bool
+		trimStart, trimEnd float64
+	}{
+		{"black fade in and out", long, edge(30, []interval{{0, 1.5}}), edge(30, []interval{{28, 30}}), true, false, 1.5, 2},
+		{"silence alone when selected", long, edge(30, nil, interval{0, 3}), edge(30, nil), false, true, 3, 0},
+		{"both: only what is black and silent", long, edge(30, []interval{{0, 1}}, interval{0, 4}), edge(30, []interval{{25, 30}}, interval{29, 30}), true, true, 1, 1},
+		{"both: silent but visible start kept", long, edge(30, nil, interval{0, 4}), edge(30, nil), true, true, 0, 0},
+		{"all-silent long clip", long, edge(30, nil, interval{0, 30}), edge(30, nil, interval{0, 30}), true, true, 0, 0},
+		{"all-silent long clip, silence only", long, edge(30, nil, interval{0, 30}), edge(30, nil, interval{0, 30}), false, true, 0, 0},
+		{"dark night clip", long, edge(30, []interval{{0, 30}}), edge(30, []interval{{0, 30}}), true, false, 0, 0},
+		{"black up to the window edge", long, edge(30, []interval{{0, 29.9}}), edge(30, nil), true, false, 0, 0},
+		{"all-black short clip", short, edge(10, []interval{{0, 10}}), edge(10, []interval{{0, 10}}), true, true, 0, 0},
+		{"trims would leave under a second", short, edge(10, []interval{{0, 5}}), edge(10, []interval{{5.5, 10}}), true, false, 0, 0},
+		{"no audio: silence ignored", long, edgeAnalysis{black: []interval{{0, 2}}, dur: 30}, edgeAnalysis{dur: 30}, true, true, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planEdgeTrim(tt.v, tt.head, tt.tail, tt.black, tt.silence)
+			if got.TrimStart != tt.trimStart || got.TrimEnd != tt.trimEnd {
+				t.Errorf("trim = %v/%v (%s), want %v/%v", got.TrimStart, got.TrimEnd, got.Reason, tt.trimStart, tt.trimEnd)
+			}
+		})
+	}
+}
+
+func TestEdgeStretches(t *testing.T) {
+	out := `[blackdetect @ 0x1] black_start:0 black_end:1.2 black_duration:1.2
+[silencedetect @ 0x2] silence_start: -0.01
+[silencedetect @ 0x2] silence_end: 2.5 | silence_duration: 2.51
+[silencedetect @ 0x2] silence_start: 28
+`
+	black, silence := edgeStretches(out, 30)
+	if len(black) != 1 || black[0] != (interval{0, 1.2}) {
+		t.Errorf("black = %v", black)
+	}
+	if len(silence) != 2 || silence[0] != (interval{0, 2.5}) || silence[1] != (interval{28, 30}) {
+		t.Errorf("silence = %v, want the open stretch closed at the window end", silence)
+	}
+}
+
+func TestApplyEdgeTrims(t *testing.T) {
+	vs := []VideoFile{
+		testClip("a.mp4"),
+		testClip("b.mp4", func(v *VideoFile) { v.TrimStart = 5 }), // the user's trim reaches further in
+		testClip("c.mp4"),
+	}
+	trims := []EdgeTrim{
+		{Clip: 1, TrimStart: 1.5},
+		{Clip: 2, TrimStart: 2, TrimEnd: 0.75},
+	}
+	got := applyEdgeTrims(vs, trims)
+	want := [][2]float64{{1.5, 0}, {5, 0.75}, {0, 0}}
+	for i, w := range want {
+		if got[i].TrimStart != w[0] || got[i].TrimEnd != w[1] {
+			t.Errorf("clip %d trims = %.2f, %.2f, want %.2f, %.2f", i+1, got[i].TrimStart, got[i].TrimEnd, w[0], w[1])
+		}
+	}
+	if vs[0].TrimStart != 0 {
+		t.Error("applyEdgeTrims modified its input")
+	}
+
+	// the trimmed clips are read from the in point for their remaining length
+	cut, err := trimmedClips(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"-ss", "5.000", "-t", "54.250", "-i", "/clips/b.mp4"}; !slices.Equal(cut[1].inputArgs(), want) {
+		t.Errorf("inputArgs = %q, want %q", cut[1].inputArgs(), want)
+	}
+	if want := []string{"-i", "/clips/c.mp4"}; !slices.Equal(cut[2].inputArgs(), want) {
+		t.Errorf("inputArgs of an untrimmed clip = %q, want %q", cut[2].inputArgs(), want)
+	}
+}
@@ -0,0 +1,703 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputCodec is the video codec used when clips have to be re-encoded.
+type OutputCodec string
+
+const (
+	// CodecAuto keeps the source codec when stream copy is possible and
+	// re-encodes to H.264 otherwise.
+	CodecAuto OutputCodec = ""
+	CodecH264 OutputCodec = "h264"
+	CodecHEVC OutputCodec = "hevc"
+	CodecAV1  OutputCodec = "av1"
+	CodecVP9  OutputCodec = "vp9"
+)
+
+// Candidate encoders, in order of preference for each codec. Hardware vendors
+// are tried NVENC, then QSV, then AMF.
+var hwEncoders = map[OutputCodec][]string{
+	CodecH264: {"h264_nvenc", "h264_qsv", "h264_amf"},
+	CodecHEVC: {"hevc_nvenc", "hevc_qsv", "hevc_amf"},
+	CodecAV1:  {"av1_nvenc", "av1_qsv", "av1_amf"},
+	CodecVP9:  {"vp9_qsv"},
+}
+
+var swEncoders = map[OutputCodec][]string{
+	CodecH264: {"libx264"},
+	CodecHEVC: {"libx265"},
+	CodecAV1:  {"libsvtav1", "libaom-av1"},
+	CodecVP9:  {"libvpx-vp9"},
+}
+
+// containerCodecs lists which video codecs each output container can hold.
+var containerCodecs = map[string][]OutputCodec{
+	"mp4":  {CodecH264, CodecHEVC, CodecAV1, CodecVP9},
+	"mov":  {CodecH264, CodecHEVC},
+	"mkv":  {CodecH264, CodecHEVC, CodecAV1, CodecVP9},
+	"webm": {CodecVP9, CodecAV1},
+	"ts":   {CodecH264, CodecHEVC},
+}
+
+func isHardwareEncoder(name string) bool {
+	for _, names := range hwEncoders {
+		if slices.Contains(names, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// softwareEncoders reports which software candidates are in the build.
+func softwareEncoders(caps *FFmpegCapabilities) map[string]bool {
+	have := map[string]bool{}
+	for _, names := range swEncoders {
+		for _, name := range names {
+			have[name] = caps.hasEncoder(name)
+		}
+	}
+	return have
+}
+
+// verifyHardwareEncoders reports which hardware encoders in the build pass
+// a trial encode. A listed hardware encoder only means ffmpeg was built with
+// it. The checks run in parallel since a missing device can take a while to
+// time out.
+func verifyHardwareEncoders(caps *FFmpegCapabilities) map[string]bool {
+	have := map[string]bool{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, names := range hwEncoders {
+		for _, name := range names {
+			if !caps.hasEncoder(name) {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok := verifyEncoder(name)
+				mu.Lock()
+				have[name] = ok
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+	return have
+}
+
+// verifiedEncoders caches trial encode results, keyed by ffmpeg path and
+// encoder, so each encoder is only tested once per binary.
+var (
+	verifiedMu       sync.Mutex
+	verifiedEncoders = map[string]bool{}
+)
+
+// verifyEncoder runs a tiny trial encode from a testsrc input. Builds compiled
+// with NVENC/QSV/AMF support list those encoders even with no driver or device.
+func verifyEncoder(name string) bool {
+	key := ffmpegBin() + "|" + name
+	verifiedMu.Lock()
+	ok, cached := verifiedEncoders[key]
+	verifiedMu.Unlock()
+	if cached {
+		return ok
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, ffmpegBin(), "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "testsrc=size=320x240:rate=30", "-frames:v", "5",
+		"-c:v", name, "-f", "null", "-")
+	out, err := cmd.CombinedOutput()
+	ok = err == nil
+	if !ok {
+		log.Printf("[encoder-check] %s unavailable: %v\n%s", name, err, strings.TrimSpace(string(out)))
+	}
+
+	verifiedMu.Lock()
+	verifiedEncoders[key] = ok
+	verifiedMu.Unlock()
+	return ok
+}
+
+type EncArgs struct {
+	Codec []string
+	Name  string // encoder actually used (shown in the UI)
+}
+
+// RateControlMode selects how the encoder spends bits.
+type RateControlMode string
+
+const (
+	RateQuality RateControlMode = "quality" // constant quality (CRF/CQ/ICQ/QVBR)
+	RateABR     RateControlMode = "abr"     // average bitrate
+	RateCVBR    RateControlMode = "cvbr"    // constrained VBR: average bitrate with a maxrate/bufsize ceiling
+	RateCBR     RateControlMode = "cbr"     // constant bitrate
+	// RateTargetSize encodes the whole timeline in two passes to hit TargetSizeMB
+	RateTargetSize RateControlMode = "size"
+)
+
+// RateControl describes the selected rate control. Bitrates are in kbit/s.
+type RateControl struct {
+	Mode    RateControlMode `json:"mode"`
+	Bitrate int             `json:"bitrate"` // target/average bitrate (abr, cvbr, cbr)
+	MaxRate int             `json:"maxRate"` // ceiling (cvbr)
+	BufSize int             `json:"bufSize"` // VBV buffer; 0 picks a default
+
+	TargetSizeMB float64 `json:"targetSizeMB"` // output size limit in MiB (size)
+}
+
+// validate checks the fields required by the mode.
+func (rc RateControl) validate() error {
+	switch rc.Mode {
+	case RateQuality:
+		return nil
+	case RateABR, RateCBR:
+		if rc.Bitrate <= 0 {
+			return fmt.Errorf("%s rate control needs a bitrate", rc.Mode)
+		}
+	case RateCVBR:
+		if rc.Bitrate <= 0 || rc.MaxRate <= 0 {
+			return fmt.Errorf("constrained VBR needs a bitrate and a maxrate")
+		}
+		if rc.MaxRate < rc.Bitrate {
+			return fmt.Errorf("maxrate (%dk) must not be below the bitrate (%dk)", rc.MaxRate, rc.Bitrate)
+		}
+	case RateTargetSize:
+		if rc.TargetSizeMB <= 0 {
+			return fmt.Errorf("target size mode needs a size in MB")
+		}
+	default:
+		return fmt.Errorf("unknown rate control mode %q", rc.Mode)
+	}
+	if rc.BufSize < 0 {
+		return fmt.Errorf("bufsize must not be negative")
+	}
+	return nil
+}
+
+// limits returns the effective bitrate ceiling and VBV buffer, or zeros when
+// the mode doesn't cap the bitrate.
+func (rc RateControl) limits() (maxRate, bufSize int) {
+	switch rc.Mode {
+	case RateCVBR:
+		maxRate, bufSize = rc.MaxRate, 2*rc.MaxRate
+	case RateCBR:
+		maxRate, bufSize = rc.Bitrate, rc.Bitrate
+	default:
+		return 0, 0
+	}
+	if rc.BufSize > 0 {
+		bufSize = rc.BufSize
+	}
+	return maxRate, bufSize
+}
+
+// KeyframeOptions controls GOP structure. Regular, closed GOPs without
+// scene-cut keyframes give even segments when the output is cut for streaming.
+type KeyframeOptions struct {
+	GOP          int  `json:"gop"`          // keyframe interval in frames (-g); 0 = encoder default
+	SceneCut     bool `json:"sceneCut"`     // allow extra keyframes on scene changes
+	ClosedGOP    bool `json:"closedGop"`    // no references across keyframes
+	ForceAtJoins bool `json:"forceAtJoins"` // keyframe exactly at every clip boundary
+}
+
+// DefaultKeyframeOptions leaves GOP size and scene cuts to the encoder.
+var DefaultKeyframeOptions = KeyframeOptions{SceneCut: true, ForceAtJoins: true}
+
+func (k KeyframeOptions) validate() error {
+	if k.GOP < 0 {
+		return fmt.Errorf("keyframe interval must not be negative")
+	}
+	return nil
+}
+
+// encodeSpec is everything needed to pick an encoder and build its arguments.
+type encodeSpec struct {
+	codec   OutputCodec
+	useHW   bool
+	quality int  // unified 0-100 quality level
+	tenBit  bool // 10-bit profile and pixel format (used to keep HDR)
+	rc      RateControl
+	twoPass bool // restrict to encoders that support two-pass
+
+	keyframes KeyframeOptions
+}
+
+// DefaultQuality matches the CRF 20 libx264 default used before the unified scale.
+const DefaultQuality = 70
+
+// qualityTiers are named points on the unified quality scale.
+var qualityTiers = map[string]int{
+	"low":       40,
+	"medium":    60,
+	"high":      DefaultQuality,
+	"very high": 85,
+	"best":      100,
+}
+
+// qualityScale maps the unified 0-100 level onto each encoder's own quantizer:
+// {value at level 0, value at level 100}. The ranges are calibrated so a given
+// level looks about the same on every encoder of a codec, e.g. level 70 is
+// libx264 CRF 20 and NVENC/QSV/AMF 23, which encode noticeably softer at equal values.
+var qualityScale = map[string][2]float64{
+	"libx264":    {40, 12},
+	"h264_nvenc": {43, 15},
+	"h264_qsv":   {43, 15},
+	"h264_amf":   {43, 15},
+	"libx265":    {45, 17},
+	"hevc_nvenc": {48, 20},
+	"hevc_qsv":   {48, 20},
+	"hevc_amf":   {48, 20},
+	"libsvtav1":  {55, 15},
+	"libaom-av1": {55, 15},
+	"av1_nvenc":  {58, 18},
+	"av1_qsv":    {58, 18},
+	"av1_amf":    {58, 18},
+	"libvpx-vp9": {56, 15},
+	"vp9_qsv":    {56, 15},
+}
+
+// qualityValue translates a unified quality level to the encoder's CRF/CQ/ICQ/QVBR value.
+func qualityValue(encoder string, level int) string {
+	level = max(0, min(100, level))
+	r, ok := qualityScale[encoder]
+	if !ok {
+		r = qualityScale["libx264"]
+	}
+	return strconv.Itoa(int(math.Round(r[0] + (r[1]-r[0])*float64(level)/100)))
+}
+
+// qualityLevel is the inverse of qualityValue: the unified level that gives the
+// encoder's CRF/CQ value, for settings that predate the unified scale.
+func qualityLevel(encoder string, value float64) int {
+	r, ok := qualityScale[encoder]
+	if !ok {
+		r = qualityScale["libx264"]
+	}
+	return max(0, min(100, int(math.Round((value-r[0])/(r[1]-r[0])*100))))
+}
+
+// buildVideoEncoderArgs picks an encoder for the codec and returns its arguments.
+func buildVideoEncoderArgs(spec encodeSpec, have map[string]bool) EncArgs {
+	codec := spec.codec
+	if codec == CodecAuto {
+		codec = CodecH264
+	}
+	if spec.useHW && !spec.twoPass {
+		for _, name := range hwEncoders[codec] {
+			if have[name] {
+				return EncArgs{Name: name, Codec: encoderArgs(name, spec)}
+			}
+		}
+		// no usable hardware encoder -> fall back to CPU
+	}
+	names := swEncoders[codec]
+	if spec.twoPass {
+		names = slices.DeleteFunc(slices.Clone(names), func(n string) bool {
+			return !slices.Contains(twoPassEncoders, n)
+		})
+	}
+	name := names[0]
+	for _, n := range names {
+		if have[n] {
+			name = n
+			break
+		}
+	}
+	return EncArgs{Name: name, Codec: encoderArgs(name, spec)}
+}
+
+func encoderArgs(name string, spec encodeSpec) []string {
+	pixFmt := "yuv420p"
+	if spec.tenBit {
+		pixFmt = "yuv420p10le"
+		if isHardwareEncoder(name) {
+			pixFmt = "p010le"
+		}
+	}
+	var args []string
+	switch {
+	case strings.HasSuffix(name, "_nvenc"):
+		args = []string{"-preset", "p4"}
+	case strings.HasSuffix(name, "_qsv"):
+		args = []string{"-preset", "medium"}
+	case strings.HasSuffix(name, "_amf"):
+		args = []string{"-quality", "quality"}
+	case name == "libx264":
+		args = []string{"-preset", "veryfast"}
+	case name == "libx265":
+		args = []string{"-preset", "medium"}
+	case name == "libsvtav1":
+		args = []string{"-preset", "8"}
+	case name == "libaom-av1":
+		args = []string{"-cpu-used", "6", "-row-mt", "1"}
+	case name == "libvpx-vp9":
+		args = []string{"-deadline", "good", "-cpu-used", "4", "-row-mt", "1"}
+	}
+	if spec.rc.Mode == RateQuality || spec.rc.Mode == "" {
+		args = append(args, qualityArgs(name, spec.quality)...)
+	} else {
+		args = append(args, bitrateArgs(name, spec.rc)...)
+	}
+	args = append(args, keyframeArgs(name, spec.keyframes)...)
+	if spec.tenBit {
+		switch {
+		case strings.HasPrefix(name, "hevc_"):
+			args = append(args, "-profile:v", "main10")
+		case name == "libvpx-vp9":
+			args = append(args, "-profile:v", "2")
+		}
+	}
+	return coalesceParams(append(append([]string{"-c:v", name}, args...), "-pix_fmt", pixFmt))
+}
+
+// qualityArgs returns the constant-quality mode of each encoder.
+func qualityArgs(name string, level int) []string {
+	q := qualityValue(name, level)
+	switch {
+	case name == "h264_nvenc":
+		return []string{"-rc", "vbr_hq", "-cq", q, "-b:v", "0"}
+	case strings.HasSuffix(name, "_nvenc"):
+		return []string{"-rc", "vbr", "-cq", q, "-b:v", "0"}
+	case name == "h264_qsv":
+		return []string{"-rc", "icq", "-global_quality", q}
+	case strings.HasSuffix(name, "_qsv"):
+		return []string{"-global_quality", q}
+	case strings.HasSuffix(name, "_amf"):
+		return []string{"-rc", "qvbr", "-qvbr_quality_level", q}
+	case name == "libaom-av1", name == "libvpx-vp9":
+		return []string{"-crf", q, "-b:v", "0"}
+	}
+	return []string{"-crf", q}
+}
+
+// keyframeArgs translates GOP size, scene-cut and closed-GOP settings. Not
+// every encoder can switch scene detection off (QSV, AMF, libaom, libvpx);
+// hardware encoders and VP9 produce closed GOPs anyway.
+func keyframeArgs(name string, k KeyframeOptions) []string {
+	args := []string{}
+	if k.GOP > 0 {
+		args = append(args, "-g", strconv.Itoa(k.GOP))
+		// Without scene cuts, also pin the minimum so every GOP has the same length
+		switch {
+		case k.SceneCut:
+		case name == "libx264", name == "libvpx-vp9":
+			args = append(args, "-keyint_min", strconv.Itoa(k.GOP))
+		case name == "libx265":
+			args = append(args, "-x265-params", "min-keyint="+strconv.Itoa(k.GOP))
+		}
+	}
+	if !k.SceneCut {
+		switch {
+		case name == "libx264":
+			args = append(args, "-sc_threshold", "0")
+		case name == "libx265":
+			args = append(args, "-x265-params", "scenecut=0")
+		case strings.HasSuffix(name, "_nvenc"):
+			args = append(args, "-no-scenecut", "1")
+		case name == "libsvtav1":
+			args = append(args, "-svtav1-params", "scd=0")
+		}
+	}
+	if k.ClosedGOP {
+		switch {
+		case name == "libx264", strings.HasSuffix(name, "_qsv"):
+			args = append(args, "-flags", "+cgop")
+		case name == "libx265":
+			args = append(args, "-x265-params", "open-gop=0")
+		case name == "libsvtav1":
+			args = append(args, "-svtav1-params", "irefresh-type=2")
+		}
+	}
+	return args
+}
+
+// joinKeyframeArgs forces a keyframe at the start of every clip, computed
+// from the cumulative clip durations, for encodes that cover the whole timeline.
+func joinKeyframeArgs(name string, vs []VideoFile) []string {
+	if len(vs) < 2 {
+		return nil
+	}
+	times := make([]string, 0, len(vs)-1)
+	var t float64
+	for _, v := range vs[:len(vs)-1] {
+		t += v.Duration
+		times = append(times, strconv.FormatFloat(t, 'f', 3, 64))
+	}
+	args := []string{"-force_key_frames", strings.Join(times, ",")}
+	// Make the forced frames IDR so a seek lands on a clean picture
+	if name == "libx264" || name == "libx265" || strings.HasSuffix(name, "_nvenc") {
+		args = append(args, "-forced-idr", "1")
+	}
+	return args
+}
+
+// paramOptions are private encoder options that only keep their last value,
+// so multiple occurrences have to be joined into one.
+var paramOptions = []string{"-x264-params", "-x265-params", "-svtav1-params"}
+
+// coalesceParams merges repeated -x264-params/-x265-params/-svtav1-params
+// options into a single occurrence.
+func coalesceParams(args []string) []string {
+	out := make([]string, 0, len(args))
+	pos := map[string]int{}
+	for i := 0; i < len(args); i++ {
+		if slices.Contains(paramOptions, args[i]) && i+1 < len(args) {
+			if p, ok := pos[args[i]]; ok {
+				out[p] += ":" + args[i+1]
+			} else {
+				out = append(out, args[i], args[i+1])
+				pos[args[i]] = len(out) - 1
+			}
+			i++
+			continue
+		}
+		out = append(out, args[i])
+	}
+	return out
+}
+
+// bitrateArgs translates ABR, constrained VBR and CBR for each encoder.
+// QSV has no explicit mode switch: it runs CBR when maxrate equals the
+// bitrate and VBR when maxrate is higher.
+func bitrateArgs(name string, rc RateControl) []string {
+	kb := func(v int) string { return strconv.Itoa(v) + "k" }
+	maxRate, bufSize := rc.limits()
+	args := []string{}
+	switch {
+	case strings.HasSuffix(name, "_nvenc"):
+		mode := "vbr"
+		if rc.Mode == RateCBR {
+			mode = "cbr"
+		}
+		args = append(args, "-rc", mode)
+	case strings.HasSuffix(name, "_amf"):
+		mode := "vbr_peak"
+		if rc.Mode == RateCBR {
+			mode = "cbr"
+		}
+		args = append(args, "-rc", mode)
+	}
+	args = append(args, "-b:v", kb(rc.Bitrate))
+	if maxRate > 0 {
+		args = append(args, "-maxrate", kb(maxRate), "-bufsize", kb(bufSize))
+	}
+	if rc.Mode == RateCBR {
+		switch name {
+		case "libx264":
+			// signal CBR in the HRD so strict ingest servers accept it
+			args = append(args, "-minrate", kb(rc.Bitrate), "-x264-params", "nal-hrd=cbr")
+		case "libvpx-vp9", "libaom-av1":
+			args = append(args, "-minrate", kb(rc.Bitrate))
+		}
+	}
+	return args
+}
+
+// outputContainer returns the container name implied by an output file extension.
+func outputContainer(path string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch ext {
+	case "m4v":
+		return "mp4"
+	case "mts", "m2ts":
+		return "ts"
+	}
+	return ext
+}
+
+// checkContainer verifies the output container can hold the selected codec.
+func checkContainer(codec OutputCodec, path string) error {
+	if codec == CodecAuto {
+		codec = CodecH264
+	}
+	container := outputContainer(path)
+	allowed, ok := containerCodecs[container]
+	if !ok {
+		return fmt.Errorf("unsupported output container %q", filepath.Ext(path))
+	}
+	if !slices.Contains(allowed, codec) {
+		return fmt.Errorf("%s output can't be stored in .%s; choose one of: %s", strings.ToUpper(string(codec)), container, strings.Join(containersFor(codec), ", "))
+	}
+	return nil
+}
+
+// sourceAudioEncoders maps ffprobe audio codec names to the encoder names
+// audioContainerCodecs lists, where they differ.
+var sourceAudioEncoders = map[string]string{"opus": "libopus", "vorbis": "libvorbis", "mp3": "libmp3lame"}
+
+// checkCopyContainer verifies the output container can hold the clips'
+// streams as they are, for a stream copy. Codecs and containers Stitcher
+// doesn't encode, such as MPEG-2 or .avi, are left for ffmpeg to judge.
+func checkCopyContainer(vs []VideoFile, path string) error {
+	container := outputContainer(path)
+	allowed, ok := containerCodecs[container]
+	if !ok || len(vs) == 0 {
+		return nil
+	}
+	codec := OutputCodec(vs[0].Codec)
+	if others := containersFor(codec); len(others) > 0 && !slices.Contains(allowed, codec) {
+		return fmt.Errorf("%s video can't be stored in .%s; choose one of: %s", strings.ToUpper(vs[0].Codec), container, strings.Join(others, ", "))
+	}
+	for _, v := range vs {
+		if !v.HasAudio {
+			continue
+		}
+		audio := v.AudioCodec
+		if enc, ok := sourceAudioEncoders[audio]; ok {
+			audio = enc
+		}
+		if knownAudioCodec(audio) && !slices.Contains(audioContainerCodecs[container], audio) {
+			return fmt.Errorf("%s audio can't be stored in .%s", v.AudioCodec, container)
+		}
+	}
+	return nil
+}
+
+// knownAudioCodec reports whether any output container lists the encoder.
+func knownAudioCodec(name string) bool {
+	for _, codecs := range audioContainerCodecs {
+		if slices.Contains(codecs, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func containersFor(codec OutputCodec) []string {
+	var names []string
+	for c, codecs := range containerCodecs {
+		if slices.Contains(codecs, codec) {
+			names = append(names, "."+c)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// defaultExtension is the extension suggested in the save dialog for a codec.
+func defaultExtension(codec OutputCodec) string {
+	if codec == CodecVP9 {
+		return "webm"
+	}
+	return "mp4"
+}
+
+// audioCodecFor returns the audio encoder to use for a container; WebM only takes Opus/Vorbis.
+func audioCodecFor(container string) string {
+	if container == "webm" {
+		return "libopus"
+	}
+	return "aac"
+}
+
+// codecTagArgs tags HEVC as hvc1 in MP4/MOV so Apple players accept it.
+func codecTagArgs(videoCodec, output string) []string {
+	c := outputContainer(output)
+	if videoCodec == string(CodecHEVC) && (c == "mp4" || c == "mov") {
+		return []string{"-tag:v", "hvc1"}
+	}
+	return nil
+}
+
+// measureVideoBitrate reads the packet sizes of the first video stream and
+// returns the average bitrate and the highest bitrate over any window of the
+// given length, both in kbit/s.
+func measureVideoBitrate(ctx context.Context, path string, window float64) (avg, peak float64, err error) {
+	cmd := exec.CommandContext(ctx, ffprobeBin(), "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,size", "-of", "csv=p=0", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read packets of %s: %w", path, err)
+	}
+	if window <= 0 {
+		window = 1
+	}
+
+	type pkt struct {
+		t    float64
+		bits float64
+	}
+	var pkts []pkt
+	var total float64
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), ",")
+		if len(parts) < 2 {
+			continue
+		}
+		t, err1 := strconv.ParseFloat(parts[0], 64)
+		size, err2 := strconv.ParseFloat(parts[1], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		pkts = append(pkts, pkt{t, size * 8})
+		total += size * 8
+	}
+	if len(pkts) == 0 {
+		return 0, 0, fmt.Errorf("no video packets found in %s", path)
+	}
+	slices.SortFunc(pkts, func(a, b pkt) int { return cmp.Compare(a.t, b.t) })
+
+	duration := pkts[len(pkts)-1].t - pkts[0].t
+	if duration <= 0 {
+		return 0, 0, fmt.Errorf("output %s is too short to measure", path)
+	}
+	avg = total / duration / 1000
+
+	// Sliding window over the packet timeline
+	var sum float64
+	start := 0
+	for _, p := range pkts {
+		sum += p.bits
+		for p.t-pkts[start].t >= window {
+			sum -= pkts[start].bits
+			start++
+		}
+		peak = max(peak, sum/window/1000)
+	}
+	return avg, peak, nil
+}
+
+// checkOutputBitrate verifies a finished encode against the rate control and
+// returns a short report, plus whether the limits were respected.
+func checkOutputBitrate(ctx context.Context, path string, rc RateControl) (string, bool, error) {
+	maxRate, bufSize := rc.limits()
+	window := 1.0
+	if maxRate > 0 {
+		// The VBV lets the encoder burst for as long as the buffer lasts
+		window = max(1, float64(bufSize)/float64(maxRate))
+	}
+	avg, peak, err := measureVideoBitrate(ctx, path, window)
+	if err != nil {
+		return "", false, err
+	}
+	report := fmt.Sprintf("Video bitrate: average %.0f kb/s, peak %.0f kb/s over %.1fs", avg, peak, window)
+	ok := true
+	if maxRate > 0 && peak > float64(maxRate)*1.1 {
+		ok = false
+		report += fmt.Sprintf(" exceeds the %d kb/s limit", maxRate)
+	}
+	if rc.Mode != RateQuality && math.Abs(avg-float64(rc.Bitrate)) > float64(rc.Bitrate)*0.15 {
+		report += fmt.Sprintf(" (target average %d kb/s)", rc.Bitrate)
+	}
+	return report, ok, nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestCheckContainer(t *testing.T) {
+	tests := []struct {
+		codec OutputCodec
+		path  string
+		ok    bool
+	}{
+		{CodecAuto, "out.mp4", true},
+		{CodecH264, "out.MOV", true},
+		{CodecH264, "out.webm", false},
+		{CodecVP9, "out.webm", true},
+		{CodecHEVC, "out.m2ts", true},
+		{CodecAV1, "out.ts", false},
+		{CodecH264, "out.avi", false},
+	}
+	for _, tt := range tests {
+		if err := checkContainer(tt.codec, tt.path); (err == nil) != tt.ok {
+			t.Errorf("checkContainer(%s, %s) = %v, want ok=%v", tt.codec, tt.path, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckCopyContainer(t *testing.T) {
+	clip := func(codec, audio string) VideoFile {
+		return testClip("a", func(v *VideoFile) { v.Codec, v.HasAudio, v.AudioCodec = codec, audio != "", audio })
+	}
+	tests := []struct {
+		name string
+		vs   []VideoFile
+		path string
+		ok   bool
+	}{
+		{"VP9/Opus into webm", []VideoFile{clip("vp9", "opus"), clip("vp9", "opus")}, "out.webm", true},
+		{"VP9/Vorbis into webm", []VideoFile{clip("vp9", "vorbis")}, "out.webm", true},
+		{"H.264 into webm", []VideoFile{clip("h264", "aac")}, "out.webm", false},
+		{"VP9 with AAC into webm", []VideoFile{clip("vp9", "aac")}, "out.webm", false},
+		{"H.264 into avi", []VideoFile{clip("h264", "mp3")}, "out.avi", true},
+		{"MPEG-4 into flv", []VideoFile{clip("mpeg4", "")}, "out.flv", true},
+		{"MPEG-2 into mkv", []VideoFile{clip("mpeg2video", "mp2")}, "out.mkv", true},
+		{"HEVC into mov", []VideoFile{clip("hevc", "aac")}, "out.mov", true},
+		{"AV1 into ts", []VideoFile{clip("av1", "")}, "out.ts", false},
+		{"LPCM into mp4", []VideoFile{clip("h264", "pcm_s16le")}, "out.mp4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkCopyContainer(tt.vs, tt.path); (err == nil) != tt.ok {
+				t.Errorf("checkCopyContainer = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestQualityArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string // Q is replaced by the encoder's quality value
+	}{
+		{"libx264", []string{"-crf", "Q"}},
+		{"libx265", []string{"-crf", "Q"}},
+		{"libsvtav1", []string{"-crf", "Q"}},
+		{"libaom-av1", []string{"-crf", "Q", "-b:v", "0"}},
+		{"libvpx-vp9", []string{"-crf", "Q", "-b:v", "0"}},
+		{"h264_nvenc", []string{"-rc", "vbr_hq", "-cq", "Q", "-b:v", "0"}},
+		{"hevc_nvenc", []string{"-rc", "vbr", "-cq", "Q", "-b:v", "0"}},
+		{"h264_qsv", []string{"-rc", "icq", "-global_quality", "Q"}},
+		{"vp9_qsv", []string{"-global_quality", "Q"}},
+		{"h264_amf", []string{"-rc", "qvbr", "-qvbr_quality_level", "Q"}},
+		{"hevc_amf", []string{"-rc", "qvbr", "-qvbr_quality_level", "Q"}},
+		{"av1_amf", []string{"-rc", "qvbr", "-qvbr_quality_level", "Q"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := qualityValue(tt.name, 60)
+			want := slices.Clone(tt.want)
+			want[slices.Index(want, "Q")] = q
+			if got := qualityArgs(tt.name, 60); !slices.Equal(got, want) {
+				t.Errorf("qualityArgs = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBuiltInPresetQuality(t *testing.T) {
+	tests := []struct {
+		preset  string
+		encoder string
+		want    string
+	}{
+		{"MP4 (H.264) - High Quality", "libx264", "18"},
+		{"MP4 (H.264) - Medium Quality", "libx264", "23"},
+		{"WebM (VP9) - Medium Quality", "libvpx-vp9", "28"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			i := slices.IndexFunc(builtInPresets, func(p MergePreset) bool { return p.Name == tt.preset })
+			if i < 0 {
+				t.Fatalf("no built-in preset %q", tt.preset)
+			}
+			if got := qualityValue(tt.encoder, builtInPresets[i].Quality); got != tt.want {
+				t.Errorf("CRF = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQualityLevel(t *testing.T) {
+	for encoder := range qualityScale {
+		for _, level := range []int{0, 25, DefaultQuality, 100} {
+			v, _ := strconv.ParseFloat(qualityValue(encoder, level), 64)
+			if got := qualityValue(encoder, qualityLevel(encoder, v)); got != qualityValue(encoder, level) {
+				t.Errorf("%s level %d: round trip gives %s, want %s", encoder, level, got, qualityValue(encoder, level))
+			}
+		}
+	}
+}
+
+func TestRateControlValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rc   RateControl
+		ok   bool
+	}{
+		{"quality", RateControl{Mode: RateQuality}, true},
+		{"abr", RateControl{Mode: RateABR, Bitrate: 8000}, true},
+		{"abr without bitrate", RateControl{Mode: RateABR}, false},
+		{"cbr", RateControl{Mode: RateCBR, Bitrate: 6000}, true},
+		{"cvbr", RateControl{Mode: RateCVBR, Bitrate: 6000, MaxRate: 9000}, true},
+		{"cvbr without maxrate", RateControl{Mode: RateCVBR, Bitrate: 6000}, false},
+		{"cvbr maxrate below bitrate", RateControl{Mode: RateCVBR, Bitrate: 6000, MaxRate: 4000}, false},
+		{"negative bufsize", RateControl{Mode: RateCBR, Bitrate: 6000, BufSize: -1}, false},
+		{"size", RateControl{Mode: RateTargetSize, TargetSizeMB: 100}, true},
+		{"size without size", RateControl{Mode: RateTargetSize}, false},
+		{"unknown", RateControl{Mode: "crf"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rc.validate(); (err == nil) != tt.ok {
+				t.Errorf("validate = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBitrateArgs(t *testing.T) {
+	abr := RateControl{Mode: RateABR, Bitrate: 8000}
+	cvbr := RateControl{Mode: RateCVBR, Bitrate: 6000, MaxRate: 9000}
+	cbr := RateControl{Mode: RateCBR, Bitrate: 6000}
+	tests := []struct {
+		name string
+		enc  string
+		rc   RateControl
+		want []string
+	}{
+		{"x264 abr", "libx264", abr, []string{"-b:v", "8000k"}},
+		{"x264 cvbr", "libx264", cvbr, []string{"-b:v", "6000k", "-maxrate", "9000k", "-bufsize", "18000k"}},
+		{"x264 cvbr bufsize", "libx264", RateControl{Mode: RateCVBR, Bitrate: 6000, MaxRate: 9000, BufSize: 4500},
+			[]string{"-b:v", "6000k", "-maxrate", "9000k", "-bufsize", "4500k"}},
+		{"x264 cbr", "libx264", cbr, []string{"-b:v", "6000k", "-maxrate", "6000k", "-bufsize", "6000k", "-minrate", "6000k", "-x264-params", "nal-hrd=cbr"}},
+		{"x265 cbr", "libx265", cbr, []string{"-b:v", "6000k", "-maxrate", "6000k", "-bufsize", "6000k"}},
+		{"vp9 cbr", "libvpx-vp9", cbr, []string{"-b:v", "6000k", "-maxrate", "6000k", "-bufsize", "6000k", "-minrate", "6000k"}},
+		{"nvenc abr", "h264_nvenc", abr, []string{"-rc", "vbr", "-b:v", "8000k"}},
+		{"nvenc cbr", "hevc_nvenc", cbr, []string{"-rc", "cbr", "-b:v", "6000k", "-maxrate", "6000k", "-bufsize", "6000k"}},
+		{"amf cvbr", "h264_amf", cvbr, []string{"-rc", "vbr_peak", "-b:v", "6000k", "-maxrate", "9000k", "-bufsize", "18000k"}},
+		{"amf cbr", "av1_amf", cbr, []string{"-rc", "cbr", "-b:v", "6000k", "-maxrate", "6000k", "-bufsize", "6000k"}},
+		{"qsv cbr", "h264_qsv", cbr, []string{"-b:v", "6000k", "-maxrate", "6000k", "-bufsize", "6000k"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bitrateArgs(tt.enc, tt.rc); !slices.Equal(got, tt.want) {
+				t.Errorf("bitrateArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyframeArgs(t *testing.T) {
+	streaming := KeyframeOptions{GOP: 60, ClosedGOP: true}
+	tests := []struct {
+		name string
+		enc  string
+		k    KeyframeOptions
+		want []string
+	}{
+		{"defaults", "libx264", DefaultKeyframeOptions, []string{}},
+		{"x264 gop with scene cuts", "libx264", KeyframeOptions{GOP: 48, SceneCut: true}, []string{"-g", "48"}},
+		{"x264 streaming", "libx264", streaming, []string{"-g", "60", "-keyint_min", "60", "-sc_threshold", "0", "-flags", "+cgop"}},
+		{"x265 streaming", "libx265", streaming, []string{"-g", "60", "-x265-params", "min-keyint=60:scenecut=0:open-gop=0"}},
+		{"vp9 streaming", "libvpx-vp9", streaming, []string{"-g", "60", "-keyint_min", "60"}},
+		{"svt-av1 streaming", "libsvtav1", streaming, []string{"-g", "60", "-svtav1-params", "scd=0:irefresh-type=2"}},
+		{"nvenc streaming", "h264_nvenc", streaming, []string{"-g", "60", "-no-scenecut", "1"}},
+		{"qsv streaming", "hevc_qsv", streaming, []string{"-g", "60", "-flags", "+cgop"}},
+		{"amf streaming", "h264_amf", streaming, []string{"-g", "60"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coalesceParams(keyframeArgs(tt.enc, tt.k)); !slices.Equal(got, tt.want) {
+				t.Errorf("keyframeArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinKeyframeArgs(t *testing.T) {
+	vs := []VideoFile{
+		testClip("a.mp4", func(v *VideoFile) { v.Duration = 10.5 }),
+		testClip("b.mp4", func(v *VideoFile) { v.Duration = 20.25 }),
+		testClip("c.mp4"),
+	}
+	if got, want := joinKeyframeArgs("libx264", vs), []string{"-force_key_frames", "10.500,30.750", "-forced-idr", "1"}; !slices.Equal(got, want) {
+		t.Errorf("joinKeyframeArgs(libx264) = %q, want %q", got, want)
+	}
+	if got, want := joinKeyframeArgs("libvpx-vp9", vs), []string{"-force_key_frames", "10.500,30.750"}; !slices.Equal(got, want) {
+		t.Errorf("joinKeyframeArgs(libvpx-vp9) = %q, want %q", got, want)
+	}
+	if got := joinKeyframeArgs("libx264", vs[:1]); got != nil {
+		t.Errorf("joinKeyframeArgs for one clip = %q, want nil", got)
+	}
+}
+
+func TestCoalesceParams(t *testing.T) {
+	in := []string{"-c:v", "libx265", "-x265-params", "a=1", "-crf", "20", "-x265-params", "b=2", "out.mkv"}
+	want := []string{"-c:v", "libx265", "-x265-params", "a=1:b=2", "-crf", "20", "out.mkv"}
+	if got := coalesceParams(in); !slices.Equal(got, want) {
+		t.Errorf("coalesceParams = %q, want %q", got, want)
+	}
+}
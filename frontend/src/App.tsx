@@ -4,6 +4,7 @@ import { main } from "../wailsjs/go/models";
 import {
     CancelMerge,
     GetHardwareEncoders,
+    GetSettings,
     GetVideoMetadata,
     MergeVideos,
     SelectVideos,
@@ -257,17 +258,25 @@ function App() {
             try {
                 const encs = await GetHardwareEncoders();
                 setAvailableGpuEncoders(encs || []);
-                // lấy trạng thái đã lưu, chỉ bật nếu có encoder khả dụng
-                const saved = localStorage.getItem("useHW") === "true";
-                const next = !!saved && (encs && encs.length > 0);
-                setUseGpu(next);
-                await SetUseHardwareEncoder(next);
+                // the backend has already loaded the saved choice
+                const settings = await GetSettings();
+                setUseGpu(settings.useHardwareEncoder && !!encs && encs.length > 0);
             } catch (e) {
                 setAvailableGpuEncoders([]);
                 setUseGpu(false);
-                await SetUseHardwareEncoder(false);
             }
         })();
+
+        // hardware encoders are verified in the background after startup
+        EventsOn("hardwareEncoders", async (encs: string[]) => {
+            setAvailableGpuEncoders(encs || []);
+            try {
+                const settings = await GetSettings();
+                setUseGpu(settings.useHardwareEncoder && !!encs && encs.length > 0);
+            } catch (e) {
+                setUseGpu(false);
+            }
+        });
     }, []);
 
     useEffect(() => {
@@ -302,6 +311,22 @@ function App() {
             }
         });
         
+        // folder imports probe files in the background and report each one
+        let importSkipped = 0;
+        EventsOn("importProgress", (data: any) => {
+            if (!data || typeof data !== 'object') return;
+            if (data.done === 1) importSkipped = 0;
+            if (data.skipped) importSkipped++;
+            const skipped = importSkipped > 0 ? `, ${importSkipped} skipped` : '';
+            setProgressText(`Scanning ${data.done}/${data.total}: ${data.file}${skipped}`);
+            if (data.done === data.total) {
+                setProgressText("");
+                if (importSkipped > 0) {
+                    pushToast('info', `${importSkipped} file(s) skipped: not videos`);
+                }
+            }
+        });
+
         EventsOn("mergeCancelled", () => {
             setStatusMessage("Merge operation cancelled.");
             setIsMerging(false);
@@ -417,7 +442,6 @@ function App() {
     async function handleToggleGpu(checked: boolean) {
         const allowed = checked && availableGpuEncoders.length > 0 && !isMerging;
         setUseGpu(allowed);
-        localStorage.setItem("useHW", allowed ? "true" : "false");
         await SetUseHardwareEncoder(allowed);
     }
 
@@ -2,18 +2,98 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function ApplyPreset(arg1:string):Promise<void>;
+
+export function BrowseForBinary(arg1:string):Promise<string>;
+
 export function CancelMerge():Promise<void>;
 
+export function CheckFastMerge(arg1:Array<main.VideoFile>):Promise<Array<main.CopyIncompatibility>>;
+
+export function CreatePreset(arg1:main.MergePreset):Promise<main.MergePreset>;
+
+export function DeletePreset(arg1:string):Promise<void>;
+
+export function DetectEdgeTrims(arg1:Array<main.VideoFile>):Promise<Array<main.EdgeTrim>>;
+
+export function DetectOverlaps(arg1:Array<main.VideoFile>):Promise<Array<main.Overlap>>;
+
+export function DetectSplitRecordings(arg1:Array<main.VideoFile>):Promise<Array<main.SplitRecording>>;
+
+export function ExportPresets(arg1:Array<string>):Promise<string>;
+
 export function GenerateThumbnail(arg1:string):Promise<string>;
 
+export function GetBinaryStatus():Promise<main.BinaryStatus>;
+
+export function GetFFmpegDiagnostics():Promise<main.FFmpegCapabilities>;
+
 export function GetHardwareEncoders():Promise<Array<string>>;
 
+export function GetJobs():Promise<Array<main.MergeJob>>;
+
+export function GetOutputCodecs():Promise<Array<string>>;
+
 export function GetPresets():Promise<Array<main.MergePreset>>;
 
+export function GetQualityTiers():Promise<Record<string, number>>;
+
+export function GetSettings():Promise<main.Settings>;
+
 export function GetVideoMetadata(arg1:string):Promise<main.VideoFile>;
 
+export function ImportCameraFolder():Promise<Array<main.RecordingSession>>;
+
+export function ImportFolder(arg1:main.FolderImport):Promise<Array<main.VideoFile>>;
+
+export function ImportPresets():Promise<Array<main.MergePreset>>;
+
 export function MergeVideos(arg1:Array<main.VideoFile>):Promise<string>;
 
+export function NewProject():Promise<void>;
+
+export function OpenProject():Promise<main.OpenedProject>;
+
+export function QueueMerge(arg1:string,arg2:Array<main.VideoFile>):Promise<main.MergeJob>;
+
+export function QueueSessions(arg1:Array<main.RecordingSession>):Promise<Array<main.MergeJob>>;
+
+export function RemoveJob(arg1:string):Promise<void>;
+
+export function SaveProject(arg1:Array<main.VideoFile>,arg2:string):Promise<string>;
+
 export function SelectVideos():Promise<Array<main.VideoFile>>;
 
+export function SetEdgeTrimming(arg1:boolean,arg2:boolean):Promise<void>;
+
+export function SetFFmpegPaths(arg1:string,arg2:string):Promise<main.BinaryStatus>;
+
+export function SetHDRMode(arg1:string):Promise<void>;
+
+export function SetIntermediateFormat(arg1:string):Promise<void>;
+
+export function SetKeyframeOptions(arg1:main.KeyframeOptions):Promise<void>;
+
+export function SetMergeStrategy(arg1:string):Promise<void>;
+
+export function SetOutputCodec(arg1:string):Promise<void>;
+
+export function SetQuality(arg1:number):Promise<void>;
+
+export function SetRateControl(arg1:main.RateControl):Promise<void>;
+
+export function SetRemoveOverlaps(arg1:boolean):Promise<void>;
+
 export function SetUseHardwareEncoder(arg1:boolean):Promise<void>;
+
+export function SortVideos(arg1:Array<main.VideoFile>,arg2:string):Promise<Array<main.VideoFile>>;
+
+export function SplitFolderByTime(arg1:main.TimeSplit):Promise<Array<main.MergeJob>>;
+
+export function StartQueue():Promise<void>;
+
+export function StopQueue():Promise<void>;
+
+export function UpdatePreset(arg1:string,arg2:main.MergePreset):Promise<main.MergePreset>;
+
+export function UpdateSettings(arg1:main.Settings):Promise<main.Settings>;
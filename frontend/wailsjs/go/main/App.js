@@ -2,34 +2,194 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function ApplyPreset(arg1) {
+  return window['go']['main']['App']['ApplyPreset'](arg1);
+}
+
+export function BrowseForBinary(arg1) {
+  return window['go']['main']['App']['BrowseForBinary'](arg1);
+}
+
 export function CancelMerge() {
   return window['go']['main']['App']['CancelMerge']();
 }
 
+export function CheckFastMerge(arg1) {
+  return window['go']['main']['App']['CheckFastMerge'](arg1);
+}
+
+export function CreatePreset(arg1) {
+  return window['go']['main']['App']['CreatePreset'](arg1);
+}
+
+export function DeletePreset(arg1) {
+  return window['go']['main']['App']['DeletePreset'](arg1);
+}
+
+export function DetectEdgeTrims(arg1) {
+  return window['go']['main']['App']['DetectEdgeTrims'](arg1);
+}
+
+export function DetectOverlaps(arg1) {
+  return window['go']['main']['App']['DetectOverlaps'](arg1);
+}
+
+export function DetectSplitRecordings(arg1) {
+  return window['go']['main']['App']['DetectSplitRecordings'](arg1);
+}
+
+export function ExportPresets(arg1) {
+  return window['go']['main']['App']['ExportPresets'](arg1);
+}
+
 export function GenerateThumbnail(arg1) {
   return window['go']['main']['App']['GenerateThumbnail'](arg1);
 }
 
+export function GetBinaryStatus() {
+  return window['go']['main']['App']['GetBinaryStatus']();
+}
+
+export function GetFFmpegDiagnostics() {
+  return window['go']['main']['App']['GetFFmpegDiagnostics']();
+}
+
 export function GetHardwareEncoders() {
   return window['go']['main']['App']['GetHardwareEncoders']();
 }
 
+export function GetJobs() {
+  return window['go']['main']['App']['GetJobs']();
+}
+
+export function GetOutputCodecs() {
+  return window['go']['main']['App']['GetOutputCodecs']();
+}
+
 export function GetPresets() {
   return window['go']['main']['App']['GetPresets']();
 }
 
+export function GetQualityTiers() {
+  return window['go']['main']['App']['GetQualityTiers']();
+}
+
+export function GetSettings() {
+  return window['go']['main']['App']['GetSettings']();
+}
+
 export function GetVideoMetadata(arg1) {
   return window['go']['main']['App']['GetVideoMetadata'](arg1);
 }
 
+export function ImportCameraFolder() {
+  return window['go']['main']['App']['ImportCameraFolder']();
+}
+
+export function ImportFolder(arg1) {
+  return window['go']['main']['App']['ImportFolder'](arg1);
+}
+
+export function ImportPresets() {
+  return window['go']['main']['App']['ImportPresets']();
+}
+
 export function MergeVideos(arg1) {
   return window['go']['main']['App']['MergeVideos'](arg1);
 }
 
+export function NewProject() {
+  return window['go']['main']['App']['NewProject']();
+}
+
+export function OpenProject() {
+  return window['go']['main']['App']['OpenProject']();
+}
+
+export function QueueMerge(arg1, arg2) {
+  return window['go']['main']['App']['QueueMerge'](arg1, arg2);
+}
+
+export function QueueSessions(arg1) {
+  return window['go']['main']['App']['QueueSessions'](arg1);
+}
+
+export function RemoveJob(arg1) {
+  return window['go']['main']['App']['RemoveJob'](arg1);
+}
+
+export function SaveProject(arg1, arg2) {
+  return window['go']['main']['App']['SaveProject'](arg1, arg2);
+}
+
 export function SelectVideos() {
   return window['go']['main']['App']['SelectVideos']();
 }
 
+export function SetEdgeTrimming(arg1, arg2) {
+  return window['go']['main']['App']['SetEdgeTrimming'](arg1, arg2);
+}
+
+export function SetFFmpegPaths(arg1, arg2) {
+  return window['go']['main']['App']['SetFFmpegPaths'](arg1, arg2);
+}
+
+export function SetHDRMode(arg1) {
+  return window['go']['main']['App']['SetHDRMode'](arg1);
+}
+
+export function SetIntermediateFormat(arg1) {
+  return window['go']['main']['App']['SetIntermediateFormat'](arg1);
+}
+
+export function SetKeyframeOptions(arg1) {
+  return window['go']['main']['App']['SetKeyframeOptions'](arg1);
+}
+
+export function SetMergeStrategy(arg1) {
+  return window['go']['main']['App']['SetMergeStrategy'](arg1);
+}
+
+export function SetOutputCodec(arg1) {
+  return window['go']['main']['App']['SetOutputCodec'](arg1);
+}
+
+export function SetQuality(arg1) {
+  return window['go']['main']['App']['SetQuality'](arg1);
+}
+
+export function SetRateControl(arg1) {
+  return window['go']['main']['App']['SetRateControl'](arg1);
+}
+
+export function SetRemoveOverlaps(arg1) {
+  return window['go']['main']['App']['SetRemoveOverlaps'](arg1);
+}
+
 export function SetUseHardwareEncoder(arg1) {
   return window['go']['main']['App']['SetUseHardwareEncoder'](arg1);
 }
+
+export function SortVideos(arg1, arg2) {
+  return window['go']['main']['App']['SortVideos'](arg1, arg2);
+}
+
+export function SplitFolderByTime(arg1) {
+  return window['go']['main']['App']['SplitFolderByTime'](arg1);
+}
+
+export function StartQueue() {
+  return window['go']['main']['App']['StartQueue']();
+}
+
+export function StopQueue() {
+  return window['go']['main']['App']['StopQueue']();
+}
+
+export function UpdatePreset(arg1, arg2) {
+  return window['go']['main']['App']['UpdatePreset'](arg1, arg2);
+}
+
+export function UpdateSettings(arg1) {
+  return window['go']['main']['App']['UpdateSettings'](arg1);
+}
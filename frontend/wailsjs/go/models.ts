@@ -1,9 +1,164 @@
 export namespace main {
 	
+	export class BinaryStatus {
+	    ffmpegPath: string;
+	    ffprobePath: string;
+	    ffmpegError?: string;
+	    ffprobeError?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BinaryStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ffmpegPath = source["ffmpegPath"];
+	        this.ffprobePath = source["ffprobePath"];
+	        this.ffmpegError = source["ffmpegError"];
+	        this.ffprobeError = source["ffprobeError"];
+	    }
+	}
+	export class CopyIncompatibility {
+	    clip: number;
+	    fileName: string;
+	    property: string;
+	    value: string;
+	    expected: string;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CopyIncompatibility(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.clip = source["clip"];
+	        this.fileName = source["fileName"];
+	        this.property = source["property"];
+	        this.value = source["value"];
+	        this.expected = source["expected"];
+	        this.message = source["message"];
+	    }
+	}
+	export class EdgeTrim {
+	    clip: number;
+	    fileName: string;
+	    trimStart: number;
+	    trimEnd: number;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new EdgeTrim(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.clip = source["clip"];
+	        this.fileName = source["fileName"];
+	        this.trimStart = source["trimStart"];
+	        this.trimEnd = source["trimEnd"];
+	        this.reason = source["reason"];
+	    }
+	}
+	export class FFmpegCapabilities {
+	    version: string;
+	    libavcodec: string;
+	    minimumVersion: string;
+	    supported: boolean;
+	    buildConfig: string[];
+	    encoders: string[];
+	    decoders: string[];
+	    filters: string[];
+	    hardwareEncoders: Record<string, boolean>;
+	
+	    static createFrom(source: any = {}) {
+	        return new FFmpegCapabilities(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.libavcodec = source["libavcodec"];
+	        this.minimumVersion = source["minimumVersion"];
+	        this.supported = source["supported"];
+	        this.buildConfig = source["buildConfig"];
+	        this.encoders = source["encoders"];
+	        this.decoders = source["decoders"];
+	        this.filters = source["filters"];
+	        this.hardwareEncoders = source["hardwareEncoders"];
+	    }
+	}
+	export class FolderImport {
+	    recursive: boolean;
+	    extensions: string[];
+	    probe: boolean;
+	    sort: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FolderImport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.recursive = source["recursive"];
+	        this.extensions = source["extensions"];
+	        this.probe = source["probe"];
+	        this.sort = source["sort"];
+	    }
+	}
+	export class KeyframeOptions {
+	    gop: number;
+	    sceneCut: boolean;
+	    closedGop: boolean;
+	    forceAtJoins: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new KeyframeOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.gop = source["gop"];
+	        this.sceneCut = source["sceneCut"];
+	        this.closedGop = source["closedGop"];
+	        this.forceAtJoins = source["forceAtJoins"];
+	    }
+	}
+	export class RateControl {
+	    mode: string;
+	    bitrate: number;
+	    maxRate: number;
+	    bufSize: number;
+	    targetSizeMB: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RateControl(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.mode = source["mode"];
+	        this.bitrate = source["bitrate"];
+	        this.maxRate = source["maxRate"];
+	        this.bufSize = source["bufSize"];
+	        this.targetSizeMB = source["targetSizeMB"];
+	    }
+	}
 	export class MergePreset {
 	    name: string;
 	    format: string;
+	    builtIn: boolean;
+	    videoCodec: string;
 	    quality: number;
+	    rateControl: RateControl;
+	    resolutionPolicy: string;
+	    width: number;
+	    height: number;
+	    fpsPolicy: string;
+	    fps: number;
+	    audioCodec: string;
+	    audioChannels: number;
+	    audioSampleRate: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new MergePreset(source);
@@ -13,8 +168,37 @@ export namespace main {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.name = source["name"];
 	        this.format = source["format"];
+	        this.builtIn = source["builtIn"];
+	        this.videoCodec = source["videoCodec"];
 	        this.quality = source["quality"];
+	        this.rateControl = this.convertValues(source["rateControl"], RateControl);
+	        this.resolutionPolicy = source["resolutionPolicy"];
+	        this.width = source["width"];
+	        this.height = source["height"];
+	        this.fpsPolicy = source["fpsPolicy"];
+	        this.fps = source["fps"];
+	        this.audioCodec = source["audioCodec"];
+	        this.audioChannels = source["audioChannels"];
+	        this.audioSampleRate = source["audioSampleRate"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class VideoFile {
 	    path: string;
@@ -26,9 +210,30 @@ export namespace main {
 	    thumbnailBase64: string;
 	    hasAudio: boolean;
 	    fps: number;
+	    frameRate: string;
 	    pixelFormat: string;
 	    sampleRate: number;
 	    channelLayout: string;
+	    rotation: number;
+	    colorPrimaries: string;
+	    colorTransfer: string;
+	    colorSpace: string;
+	    colorRange: string;
+	    bitDepth: number;
+	    masteringDisplay: string;
+	    maxCll: string;
+	    audioCodec: string;
+	    profile: string;
+	    level: number;
+	    timeBase: string;
+	    sar: string;
+	    fieldOrder: string;
+	    extradataHash: string;
+	    container: string;
+	    startTime: number;
+	    creationTime: string;
+	    trimStart: number;
+	    trimEnd: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new VideoFile(source);
@@ -45,9 +250,340 @@ export namespace main {
 	        this.thumbnailBase64 = source["thumbnailBase64"];
 	        this.hasAudio = source["hasAudio"];
 	        this.fps = source["fps"];
+	        this.frameRate = source["frameRate"];
 	        this.pixelFormat = source["pixelFormat"];
 	        this.sampleRate = source["sampleRate"];
 	        this.channelLayout = source["channelLayout"];
+	        this.rotation = source["rotation"];
+	        this.colorPrimaries = source["colorPrimaries"];
+	        this.colorTransfer = source["colorTransfer"];
+	        this.colorSpace = source["colorSpace"];
+	        this.colorRange = source["colorRange"];
+	        this.bitDepth = source["bitDepth"];
+	        this.masteringDisplay = source["masteringDisplay"];
+	        this.maxCll = source["maxCll"];
+	        this.audioCodec = source["audioCodec"];
+	        this.profile = source["profile"];
+	        this.level = source["level"];
+	        this.timeBase = source["timeBase"];
+	        this.sar = source["sar"];
+	        this.fieldOrder = source["fieldOrder"];
+	        this.extradataHash = source["extradataHash"];
+	        this.container = source["container"];
+	        this.startTime = source["startTime"];
+	        this.creationTime = source["creationTime"];
+	        this.trimStart = source["trimStart"];
+	        this.trimEnd = source["trimEnd"];
+	    }
+	}
+	export class MergeJob {
+	    id: string;
+	    projectName: string;
+	    videoFiles: VideoFile[];
+	    preset: MergePreset;
+	    outputName: string;
+	    status: string;
+	    progress: number;
+	    outputPath: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MergeJob(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.projectName = source["projectName"];
+	        this.videoFiles = this.convertValues(source["videoFiles"], VideoFile);
+	        this.preset = this.convertValues(source["preset"], MergePreset);
+	        this.outputName = source["outputName"];
+	        this.status = source["status"];
+	        this.progress = source["progress"];
+	        this.outputPath = source["outputPath"];
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class RelinkedClip {
+	    oldPath: string;
+	    newPath: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RelinkedClip(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.oldPath = source["oldPath"];
+	        this.newPath = source["newPath"];
+	    }
+	}
+	export class Project {
+	    version: number;
+	    name: string;
+	    clips: VideoFile[];
+	    preset: MergePreset;
+	    outputPath: string;
+	    codec: string;
+	    quality: number;
+	    rateControl: RateControl;
+	    keyframes: KeyframeOptions;
+	    hdrMode: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Project(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.name = source["name"];
+	        this.clips = this.convertValues(source["clips"], VideoFile);
+	        this.preset = this.convertValues(source["preset"], MergePreset);
+	        this.outputPath = source["outputPath"];
+	        this.codec = source["codec"];
+	        this.quality = source["quality"];
+	        this.rateControl = this.convertValues(source["rateControl"], RateControl);
+	        this.keyframes = this.convertValues(source["keyframes"], KeyframeOptions);
+	        this.hdrMode = source["hdrMode"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class OpenedProject {
+	    path: string;
+	    project: Project;
+	    relinked: RelinkedClip[];
+	    missing: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new OpenedProject(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.project = this.convertValues(source["project"], Project);
+	        this.relinked = this.convertValues(source["relinked"], RelinkedClip);
+	        this.missing = source["missing"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Overlap {
+	    join: number;
+	    from: string;
+	    to: string;
+	    seconds: number;
+	    method: string;
+	    score: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Overlap(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.join = source["join"];
+	        this.from = source["from"];
+	        this.to = source["to"];
+	        this.seconds = source["seconds"];
+	        this.method = source["method"];
+	        this.score = source["score"];
+	    }
+	}
+	
+	
+	export class RecordingSession {
+	    name: string;
+	    camera: string;
+	    start: string;
+	    duration: number;
+	    clips: VideoFile[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RecordingSession(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.camera = source["camera"];
+	        this.start = source["start"];
+	        this.duration = source["duration"];
+	        this.clips = this.convertValues(source["clips"], VideoFile);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class Settings {
+	    version: number;
+	    useHardwareEncoder: boolean;
+	    outputCodec: string;
+	    quality: number;
+	    rateControl: RateControl;
+	    keyframes: KeyframeOptions;
+	    hdrMode: string;
+	    mergeStrategy: string;
+	    intermediate: string;
+	    removeOverlaps: boolean;
+	    trimBlack: boolean;
+	    trimSilence: boolean;
+	    defaultOutputDir: string;
+	    namingTemplate: string;
+	    tempDir: string;
+	    lastInputDir: string;
+	    lastOutputDir: string;
+	    workers: number;
+	    defaultPreset: string;
+	    ffmpegPath: string;
+	    ffprobePath: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.useHardwareEncoder = source["useHardwareEncoder"];
+	        this.outputCodec = source["outputCodec"];
+	        this.quality = source["quality"];
+	        this.rateControl = this.convertValues(source["rateControl"], RateControl);
+	        this.keyframes = this.convertValues(source["keyframes"], KeyframeOptions);
+	        this.hdrMode = source["hdrMode"];
+	        this.mergeStrategy = source["mergeStrategy"];
+	        this.intermediate = source["intermediate"];
+	        this.removeOverlaps = source["removeOverlaps"];
+	        this.trimBlack = source["trimBlack"];
+	        this.trimSilence = source["trimSilence"];
+	        this.defaultOutputDir = source["defaultOutputDir"];
+	        this.namingTemplate = source["namingTemplate"];
+	        this.tempDir = source["tempDir"];
+	        this.lastInputDir = source["lastInputDir"];
+	        this.lastOutputDir = source["lastOutputDir"];
+	        this.workers = source["workers"];
+	        this.defaultPreset = source["defaultPreset"];
+	        this.ffmpegPath = source["ffmpegPath"];
+	        this.ffprobePath = source["ffprobePath"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SplitRecording {
+	    kind: string;
+	    clips: number[];
+	    fileNames: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SplitRecording(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.clips = source["clips"];
+	        this.fileNames = source["fileNames"];
+	    }
+	}
+	export class TimeSplit {
+	    recursive: boolean;
+	    gapMinutes: number;
+	    byDay: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TimeSplit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.recursive = source["recursive"];
+	        this.gapMinutes = source["gapMinutes"];
+	        this.byDay = source["byDay"];
 	    }
 	}
 
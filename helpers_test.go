@@ -0,0 +1,19 @@
+package main
+
+import "path/filepath"
+
+// testClip returns a probed-looking 60 s 1080p30 H.264/AAC clip at
+// /clips/<name>, with edits applied in order.
+func testClip(name string, edits ...func(*VideoFile)) VideoFile {
+	v := VideoFile{
+		Path: filepath.Join("/clips", name), FileName: name, Duration: 60,
+		Resolution: "1920x1080", Codec: "h264", FPS: 30, FrameRate: "30/1", PixelFormat: "yuv420p",
+		HasAudio: true, AudioCodec: "aac", SampleRate: 48000, ChannelLayout: "stereo",
+		Profile: "High", Level: 41, TimeBase: "1/15360", SAR: "1:1", FieldOrder: "progressive",
+		BitDepth: 8, ColorRange: "tv",
+	}
+	for _, edit := range edits {
+		edit(&v)
+	}
+	return v
+}
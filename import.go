@@ -0,0 +1,286 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// videoExtensions are the file types SelectVideos offers and folder scans pick up.
+var videoExtensions = []string{".mp4", ".mkv", ".mov", ".avi", ".webm", ".mts", ".m2ts", ".ts", ".vob"}
+
+func hasVideoExtension(path string) bool {
+	return slices.Contains(videoExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// findVideoFiles lists the video files in dir, descending into
+// subdirectories when recursive is set. Hidden files and folders, such as
+// the ._ files macOS writes to camera cards, are skipped.
+func findVideoFiles(dir string, recursive bool) ([]string, error) {
+	return findFiles(dir, recursive, hasVideoExtension)
+}
+
+// findFiles lists the non-hidden files in dir that match keep.
+func findFiles(dir string, recursive bool, keep func(path string) bool) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subfolders don't spoil the rest of the scan
+			if path != dir {
+				log.Printf("[import] %v", err)
+				return nil
+			}
+			return err
+		}
+		hidden := strings.HasPrefix(d.Name(), ".") && path != dir
+		if d.IsDir() {
+			if hidden || (!recursive && path != dir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !hidden && keep(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return paths, nil
+}
+
+// probeFiles probes the files in parallel and returns those with a video
+// stream, in the order given. Each result goes to the UI as it arrives in an
+// "importProgress" event, so large imports fill the list progressively.
+// Files for which skip returns true are left out; skip may be nil.
+func (a *App) probeFiles(paths []string, skip func(VideoFile) bool) []VideoFile {
+	results := make([]*VideoFile, len(paths))
+	sem := make(chan struct{}, a.workerCount()*2)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	for i, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			v, err := a.probeVideo(path)
+			event := map[string]interface{}{
+				"total": len(paths),
+				"file":  filepath.Base(path),
+			}
+			if err != nil {
+				log.Printf("[import] skipping %s: %v", path, err)
+			} else if skip != nil && skip(v) {
+				log.Printf("[import] skipping %s: not a video (%s)", path, v.Container)
+				event["skipped"] = true
+			} else {
+				results[i] = &v
+				event["video"] = v
+			}
+			mu.Lock()
+			done++
+			event["done"] = done
+			runtime.EventsEmit(a.ctx, "importProgress", event)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var vs []VideoFile
+	for _, v := range results {
+		if v != nil {
+			vs = append(vs, *v)
+		}
+	}
+	return vs
+}
+
+// FolderImport configures ImportFolder.
+type FolderImport struct {
+	Recursive bool `json:"recursive"`
+	// Extensions limits the scan to these file types, e.g. [".mp4"]; empty
+	// means every known video extension
+	Extensions []string `json:"extensions"`
+	// Probe ignores extensions and keeps every file ffprobe finds a moving
+	// video stream in, for recorders with unusual file types
+	Probe bool      `json:"probe"`
+	Sort  SortOrder `json:"sort"`
+}
+
+// matcher returns the file filter for the scan.
+func (o FolderImport) matcher() func(path string) bool {
+	switch {
+	case o.Probe:
+		return func(string) bool { return true }
+	case len(o.Extensions) > 0:
+		exts := make([]string, len(o.Extensions))
+		for i, e := range o.Extensions {
+			exts[i] = "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(e)), ".")
+		}
+		return func(path string) bool { return slices.Contains(exts, strings.ToLower(filepath.Ext(path))) }
+	}
+	return hasVideoExtension
+}
+
+// isStillImage reports whether ffprobe read the file as a picture; JPEG,
+// PNG and friends have a "video" stream too. Only the demuxer is checked:
+// fragmented or still-recording videos can report no duration.
+func isStillImage(v VideoFile) bool {
+	return v.Container == "image2" || strings.HasSuffix(v.Container, "_pipe")
+}
+
+// SortOrder selects how imported clips are ordered on the timeline.
+type SortOrder string
+
+const (
+	// SortName orders by file name with numbers compared by value, so
+	// clip2 comes before clip10.
+	SortName SortOrder = "name"
+	// SortModified orders by the file's modification time.
+	SortModified SortOrder = "mtime"
+	// SortCreated orders by the creation_time the recorder embedded; clips
+	// without one go last.
+	SortCreated SortOrder = "created"
+	// SortDuration orders from shortest to longest.
+	SortDuration SortOrder = "duration"
+)
+
+func (o SortOrder) validate() error {
+	switch o {
+	case SortName, SortModified, SortCreated, SortDuration:
+		return nil
+	}
+	return fmt.Errorf("unknown sort order %q", o)
+}
+
+// ImportFolder lets the user pick a folder and returns its videos, probed
+// and sorted. Results are also streamed as "importProgress" events.
+func (a *App) ImportFolder(opts FolderImport) ([]VideoFile, error) {
+	if opts.Sort == "" {
+		opts.Sort = SortName
+	}
+	if err := opts.Sort.validate(); err != nil {
+		return nil, err
+	}
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            "Select Video Folder",
+		DefaultDirectory: a.settings.LastInputDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return []VideoFile{}, nil
+	}
+	a.settings.LastInputDir = dir
+	a.persistSettings()
+
+	paths, err := findFiles(dir, opts.Recursive, opts.matcher())
+	if err != nil {
+		return nil, err
+	}
+	var skip func(VideoFile) bool
+	if opts.Probe {
+		skip = isStillImage
+	}
+	vs := a.probeFiles(paths, skip)
+	sortVideos(vs, opts.Sort)
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": fmt.Sprintf("Imported %d video(s) from %s (%d file(s) scanned)", len(vs), dir, len(paths)),
+	})
+	return vs, nil
+}
+
+// SortVideos reorders clips already on the timeline.
+func (a *App) SortVideos(videoFiles []VideoFile, order string) ([]VideoFile, error) {
+	if err := SortOrder(order).validate(); err != nil {
+		return nil, err
+	}
+	vs := slices.Clone(videoFiles)
+	sortVideos(vs, SortOrder(order))
+	return vs, nil
+}
+
+// sortVideos sorts clips in place. Ties fall back to the natural file name
+// order, so the result doesn't depend on probing order.
+func sortVideos(vs []VideoFile, order SortOrder) {
+	byName := func(a, b VideoFile) int {
+		return cmp.Or(naturalCompare(a.FileName, b.FileName), cmp.Compare(a.Path, b.Path))
+	}
+	switch order {
+	case SortModified:
+		mtimes := make(map[string]time.Time, len(vs))
+		for _, v := range vs {
+			if fi, err := os.Stat(v.Path); err == nil {
+				mtimes[v.Path] = fi.ModTime()
+			}
+		}
+		slices.SortStableFunc(vs, func(a, b VideoFile) int {
+			return cmp.Or(mtimes[a.Path].Compare(mtimes[b.Path]), byName(a, b))
+		})
+	case SortCreated:
+		slices.SortStableFunc(vs, func(a, b VideoFile) int {
+			// Clips without a creation time sort after those with one
+			if (a.CreationTime == "") != (b.CreationTime == "") {
+				if a.CreationTime == "" {
+					return 1
+				}
+				return -1
+			}
+			ta, _ := time.Parse(time.RFC3339Nano, a.CreationTime)
+			tb, _ := time.Parse(time.RFC3339Nano, b.CreationTime)
+			return cmp.Or(ta.Compare(tb), byName(a, b))
+		})
+	case SortDuration:
+		slices.SortStableFunc(vs, func(a, b VideoFile) int {
+			return cmp.Or(cmp.Compare(a.Duration, b.Duration), byName(a, b))
+		})
+	default:
+		slices.SortStableFunc(vs, byName)
+	}
+}
+
+// naturalCompare compares file names case-insensitively, treating runs of
+// digits as numbers: "clip2" < "clip10" and "GH010123" < "GH020123".
+func naturalCompare(a, b string) int {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da == "" || db == "" {
+			if c := cmp.Compare(a[0], b[0]); c != 0 {
+				return c
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		// Compare by value: drop leading zeros, then more digits is larger
+		na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+		if c := cmp.Or(cmp.Compare(len(na), len(nb)), cmp.Compare(na, nb)); c != 0 {
+			return c
+		}
+		a, b = a[len(da):], b[len(db):]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// digitPrefix returns the run of ASCII digits s starts with.
+func digitPrefix(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool { return r > unicode.MaxASCII || !unicode.IsDigit(r) })
+	if i < 0 {
+		return s
+	}
+	return s[:i]
+}
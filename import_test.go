@@ -0,0 +1,51 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"clip2.mp4", "clip10.mp4", -1},
+		{"GH010123.MP4", "GH020123.MP4", -1},
+		{"Clip1.mp4", "clip1.MP4", 0},
+		{"clip007.mp4", "clip7.mp4", 0},
+		{"clip.mp4", "clip1.mp4", -1},
+		{"b.mp4", "a10.mp4", 1},
+	}
+	for _, tt := range tests {
+		if got := naturalCompare(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalCompare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	names := []string{"clip10.mp4", "clip9.mp4", "Clip1.mp4", "clip100.mp4"}
+	slices.SortFunc(names, naturalCompare)
+	if want := []string{"Clip1.mp4", "clip9.mp4", "clip10.mp4", "clip100.mp4"}; !slices.Equal(names, want) {
+		t.Errorf("sorted %q, want %q", names, want)
+	}
+}
+
+func TestIsStillImage(t *testing.T) {
+	tests := []struct {
+		name string
+		v    VideoFile
+		want bool
+	}{
+		{"mp4", testClip("a.mp4", func(v *VideoFile) { v.Container = "mov,mp4,m4a,3gp,3g2,mj2" }), false},
+		{"jpeg", testClip("a.jpg", func(v *VideoFile) { v.Container = "jpeg_pipe"; v.Duration = 0 }), true},
+		{"png sequence", testClip("a.png", func(v *VideoFile) { v.Container = "image2"; v.Duration = 0.04 }), true},
+		{"no duration", testClip("live.ts", func(v *VideoFile) { v.Container = "mpegts"; v.Duration = 0 }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStillImage(tt.v); got != tt.want {
+				t.Errorf("isStillImage = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
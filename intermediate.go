@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// IntermediateFormat selects how normalized clips are stored before the final join.
+type IntermediateFormat string
+
+const (
+	// IntermediateMKV stores normalized clips in Matroska, which holds every
+	// codec Stitcher writes.
+	IntermediateMKV IntermediateFormat = "mkv"
+	// IntermediateTS stores them in MPEG-TS, whose in-band headers make the
+	// join robust; VP9 and AV1 fall back to Matroska.
+	IntermediateTS IntermediateFormat = "ts"
+	// IntermediateNearLossless encodes clips to near-lossless H.264 and PCM
+	// audio in Matroska, and encodes the joined timeline once with the output
+	// settings, so rate control sees the whole merge.
+	IntermediateNearLossless IntermediateFormat = "nearlossless"
+)
+
+func (f IntermediateFormat) validate() error {
+	switch f {
+	case IntermediateMKV, IntermediateTS, IntermediateNearLossless:
+		return nil
+	}
+	return fmt.Errorf("unknown intermediate format %q", f)
+}
+
+// SetIntermediateFormat selects "mkv", "ts" or "nearlossless".
+func (a *App) SetIntermediateFormat(format string) error {
+	if err := IntermediateFormat(format).validate(); err != nil {
+		return err
+	}
+	a.intermediate = IntermediateFormat(format)
+	a.persistSettings()
+	return nil
+}
+
+// extension returns the file extension for intermediates of the given codec.
+func (f IntermediateFormat) extension(codec OutputCodec) string {
+	if f == IntermediateTS && (codec == CodecH264 || codec == CodecHEVC) {
+		return "ts"
+	}
+	return "mkv"
+}
+
+// nearLosslessEncoder returns the intermediate video encoder: fast, visually
+// lossless H.264, 10-bit when HDR is kept.
+func nearLosslessEncoder(tenBit bool) EncArgs {
+	pixFmt := "yuv420p"
+	if tenBit {
+		pixFmt = "yuv420p10le"
+	}
+	return EncArgs{Name: "libx264", Codec: []string{"-c:v", "libx264", "-preset", "superfast", "-crf", "8", "-pix_fmt", pixFmt}}
+}
+
+// pcmAudioArgs returns uncompressed audio in the target layout for near-lossless intermediates.
+func (t normTarget) pcmAudioArgs() []string {
+	return []string{"-c:a", "pcm_s16le", "-ar", strconv.Itoa(t.sampleRate), "-ac", strconv.Itoa(t.channels)}
+}
+
+// joinCopyArgs returns the bitstream filters needed to move stream-copied
+// intermediates into the output container: MPEG-TS carries AAC as ADTS,
+// which MP4 and MOV need converted.
+func joinCopyArgs(ext string, t normTarget, outputFile string) []string {
+	if c := outputContainer(outputFile); ext == "ts" && t.anyAudio && t.audioCodec == "aac" && (c == "mp4" || c == "mov") {
+		return []string{"-bsf:a", "aac_adtstoasc"}
+	}
+	return nil
+}
+
+// encodeJoined reads the near-lossless intermediates through the concat
+// demuxer and encodes them once with the output settings. A failing hardware
+// encoder is retried on the CPU.
+func (a *App) encodeJoined(ctx context.Context, listFile string, vs []VideoFile, t normTarget, outputFile string) error {
+	args := func(enc EncArgs) []string {
+		args := []string{"-y", "-hide_banner", "-loglevel", "error", "-f", "concat", "-safe", "0", "-i", listFile, "-map", "0:v:0"}
+		if t.anyAudio {
+			args = append(args, "-map", "0:a:0")
+			args = append(args, t.audioArgs()...)
+		}
+		args = append(args, enc.Codec...)
+		args = append(args, t.colorArgs(enc.Name)...)
+		args = append(args, t.keyframeArgs(enc.Name, vs)...)
+		args = coalesceParams(args)
+		args = append(args, codecTagArgs(string(t.codec), outputFile)...)
+		return append(args, outputFile)
+	}
+	total := totalDuration(vs)
+	err := a.runFFmpegProgress(ctx, args(t.enc), total, "Encoding...", 0, 100)
+	if err != nil && ctx.Err() == nil && isHardwareEncoder(t.enc.Name) {
+		cpu := t.cpuEncoder(a.encoders())
+		msg := fmt.Sprintf("%s failed, retrying with %s", t.enc.Name, cpu.Name)
+		log.Printf("[hw-fallback] %s: %v", msg, err)
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": msg,
+		})
+		err = a.runFFmpegProgress(ctx, args(cpu), total, "Encoding...", 0, 100)
+	}
+	return err
+}
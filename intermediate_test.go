@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIntermediateExtension(t *testing.T) {
+	tests := []struct {
+		f     IntermediateFormat
+		codec OutputCodec
+		want  string
+	}{
+		{IntermediateTS, CodecH264, "ts"},
+		{IntermediateTS, CodecHEVC, "ts"},
+		{IntermediateTS, CodecVP9, "mkv"},
+		{IntermediateTS, CodecAV1, "mkv"},
+		{IntermediateMKV, CodecH264, "mkv"},
+		{IntermediateNearLossless, CodecHEVC, "mkv"},
+	}
+	for _, tt := range tests {
+		if got := tt.f.extension(tt.codec); got != tt.want {
+			t.Errorf("%s.extension(%s) = %s, want %s", tt.f, tt.codec, got, tt.want)
+		}
+	}
+	if err := IntermediateFormat("mp4").validate(); err == nil {
+		t.Error("validate accepted an unknown intermediate format")
+	}
+}
+
+func TestNearLosslessEncoder(t *testing.T) {
+	tests := []struct {
+		tenBit bool
+		pixFmt string
+	}{
+		{false, "yuv420p"},
+		{true, "yuv420p10le"},
+	}
+	for _, tt := range tests {
+		enc := nearLosslessEncoder(tt.tenBit)
+		if enc.Name != "libx264" {
+			t.Errorf("nearLosslessEncoder(%v) uses %s, want libx264", tt.tenBit, enc.Name)
+		}
+		if i := slices.Index(enc.Codec, "-pix_fmt"); i < 0 || enc.Codec[i+1] != tt.pixFmt {
+			t.Errorf("nearLosslessEncoder(%v) = %q, want -pix_fmt %s", tt.tenBit, enc.Codec, tt.pixFmt)
+		}
+	}
+}
+
+func TestJoinCopyArgs(t *testing.T) {
+	aac := normTarget{anyAudio: true, audioCodec: "aac"}
+	tests := []struct {
+		name   string
+		ext    string
+		t      normTarget
+		output string
+		want   []string
+	}{
+		{"ts to mp4", "ts", aac, "out.mp4", []string{"-bsf:a", "aac_adtstoasc"}},
+		{"ts to mov", "ts", aac, "out.MOV", []string{"-bsf:a", "aac_adtstoasc"}},
+		{"ts to mkv", "ts", aac, "out.mkv", nil},
+		{"ts to ts", "ts", aac, "out.ts", nil},
+		{"mkv to mp4", "mkv", aac, "out.mp4", nil},
+		{"no audio", "ts", normTarget{}, "out.mp4", nil},
+		{"opus", "ts", normTarget{anyAudio: true, audioCodec: "libopus"}, "out.mp4", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinCopyArgs(tt.ext, tt.t, tt.output); !slices.Equal(got, tt.want) {
+				t.Errorf("joinCopyArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// mergeOptions are the encode settings a merge runs with. A queued job keeps
+// the ones current when it was queued, so settings changed while the queue
+// runs only apply to jobs queued afterwards.
+type mergeOptions struct {
+	preset       MergePreset
+	codec        OutputCodec
+	quality      int
+	rc           RateControl
+	keyframes    KeyframeOptions
+	hdrMode      HDRMode
+	strategy     MergeStrategy
+	intermediate IntermediateFormat
+	useHW        bool
+	outputDir    string // folder queued jobs are written to; "" = next to the first clip
+
+	removeOverlaps bool
+	trimBlack      bool
+	trimSilence    bool
+}
+
+// mergeOptions snapshots the current encode settings.
+func (a *App) mergeOptions() mergeOptions {
+	return mergeOptions{
+		preset:         a.preset,
+		codec:          a.codec,
+		quality:        a.quality,
+		rc:             a.rc,
+		keyframes:      a.keyframes,
+		hdrMode:        a.hdrMode,
+		strategy:       a.strategy,
+		intermediate:   a.intermediate,
+		useHW:          a.useHW,
+		outputDir:      a.settings.DefaultOutputDir,
+		removeOverlaps: a.settings.RemoveOverlaps,
+		trimBlack:      a.settings.TrimBlack,
+		trimSilence:    a.settings.TrimSilence,
+	}
+}
+
+// QueueMerge adds a merge of the clips with the current settings and preset
+// to the queue. name is the output file name without extension; "" uses the
+// naming template.
+func (a *App) QueueMerge(name string, videoFiles []VideoFile) (MergeJob, error) {
+	if len(videoFiles) < 2 {
+		return MergeJob{}, fmt.Errorf("at least two videos are required to merge")
+	}
+	ext := a.outputExtension()
+	outputName := a.outputName(videoFiles, ext)
+	if name != "" {
+		outputName = sanitizeFileName(name) + "." + ext
+	}
+	return a.queueJob(name, videoFiles, outputName), nil
+}
+
+// queueJob appends a pending job and notifies the UI.
+func (a *App) queueJob(name string, videoFiles []VideoFile, outputName string) MergeJob {
+	opts := a.mergeOptions()
+	a.jobsMu.Lock()
+	a.jobSeq++
+	job := &MergeJob{
+		ID:          fmt.Sprintf("job-%d", a.jobSeq),
+		ProjectName: name,
+		VideoFiles:  videoFiles,
+		Preset:      opts.preset,
+		OutputName:  outputName,
+		Status:      StatusPending,
+		opts:        opts,
+	}
+	a.jobs = append(a.jobs, job)
+	queued := *job
+	a.jobsMu.Unlock()
+	a.emitJobs()
+	return queued
+}
+
+// GetJobs returns a snapshot of the merge queue.
+func (a *App) GetJobs() []MergeJob {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	jobs := make([]MergeJob, len(a.jobs))
+	for i, j := range a.jobs {
+		jobs[i] = *j
+	}
+	return jobs
+}
+
+// RemoveJob drops a job that isn't running from the queue.
+func (a *App) RemoveJob(id string) error {
+	a.jobsMu.Lock()
+	i := slices.IndexFunc(a.jobs, func(j *MergeJob) bool { return j.ID == id })
+	if i < 0 {
+		a.jobsMu.Unlock()
+		return fmt.Errorf("job %q not found", id)
+	}
+	if a.jobs[i] == a.activeJob {
+		a.jobsMu.Unlock()
+		return fmt.Errorf("job %q is running", id)
+	}
+	a.jobs = slices.Delete(a.jobs, i, i+1)
+	a.jobsMu.Unlock()
+	a.emitJobs()
+	return nil
+}
+
+// StartQueue runs the pending jobs one after another in the background.
+func (a *App) StartQueue() error {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	if a.activeJob != nil {
+		return fmt.Errorf("the merge queue is already running")
+	}
+	next := a.nextJob()
+	if next == nil {
+		return fmt.Errorf("no pending jobs")
+	}
+	a.queueStop = false
+	a.activeJob = next
+	go a.runQueue()
+	return nil
+}
+
+// StopQueue cancels the running job and leaves the rest pending.
+func (a *App) StopQueue() {
+	a.jobsMu.Lock()
+	a.queueStop = true
+	a.jobsMu.Unlock()
+	if cancel := a.cancelActiveJob(); cancel != nil {
+		cancel()
+		runtime.EventsEmit(a.ctx, "mergeCancelled")
+	}
+}
+
+// cancelActiveJob returns the cancel func of the running job, nil when the
+// queue is idle.
+func (a *App) cancelActiveJob() context.CancelFunc {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	if a.activeJob == nil {
+		return nil
+	}
+	return a.activeJob.cancel
+}
+
+func (a *App) queueRunning() bool {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	return a.activeJob != nil
+}
+
+// nextJob returns the first pending job. Callers hold jobsMu.
+func (a *App) nextJob() *MergeJob {
+	for _, j := range a.jobs {
+		if j.Status == StatusPending {
+			return j
+		}
+	}
+	return nil
+}
+
+// runQueue works through the queue starting at activeJob. Jobs run
+// sequentially: each merge already keeps the machine busy.
+func (a *App) runQueue() {
+	for {
+		// The job's context is in place before it counts as running, so a
+		// stop can't slip in before there is anything to cancel
+		ctx, cancel := context.WithCancel(a.ctx)
+		a.jobsMu.Lock()
+		if a.queueStop {
+			a.activeJob = nil
+			a.jobsMu.Unlock()
+			cancel()
+			a.emitJobs()
+			return
+		}
+		job := a.activeJob
+		job.Status = StatusRunning
+		job.Progress = 0
+		job.cancel = cancel
+		a.jobsMu.Unlock()
+		a.emitJobs()
+
+		outputPath, err := a.runJob(ctx, job)
+		cancel()
+
+		a.jobsMu.Lock()
+		job.cancel = nil
+		job.OutputPath = outputPath
+		if err != nil {
+			job.Status = StatusError
+			job.Error = err.Error()
+			log.Printf("[queue] %s: %v", job.ID, err)
+		} else {
+			job.Status = StatusComplete
+			job.Progress = 100
+		}
+		a.activeJob = nil
+		if !a.queueStop {
+			a.activeJob = a.nextJob()
+		}
+		done := a.activeJob == nil
+		a.jobsMu.Unlock()
+		a.emitJobs()
+		if done {
+			return
+		}
+	}
+}
+
+// runJob merges one queued job with the settings it was queued with into the
+// output folder, or next to its first clip when no default folder is set.
+func (a *App) runJob(ctx context.Context, job *MergeJob) (string, error) {
+	dir := job.opts.outputDir
+	if dir == "" {
+		dir = filepath.Dir(job.VideoFiles[0].Path)
+	}
+	outputFile := uniquePath(filepath.Join(dir, job.OutputName))
+	a.jobsMu.Lock()
+	job.OutputPath = outputFile
+	a.jobsMu.Unlock()
+
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": fmt.Sprintf("Starting %s (%d clips) -> %s", jobName(job), len(job.VideoFiles), outputFile),
+	})
+	_, err := a.mergeTo(ctx, job.VideoFiles, outputFile, job.opts)
+	return outputFile, err
+}
+
+func jobName(job *MergeJob) string {
+	if job.ProjectName != "" {
+		return job.ProjectName
+	}
+	return job.ID
+}
+
+// setJobProgress records the running job's progress (0-100).
+func (a *App) setJobProgress(p float64) {
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	if a.activeJob != nil {
+		a.activeJob.Progress = p
+	}
+}
+
+// emitJobs sends the queue to the UI.
+func (a *App) emitJobs() {
+	runtime.EventsEmit(a.ctx, "jobsUpdated", a.GetJobs())
+}
+
+// uniquePath appends " (2)", " (3)", ... to the file name until it doesn't
+// collide with an existing file.
+func uniquePath(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		p := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Stat(p); err != nil {
+			return p
+		}
+	}
+}
+
+// sanitizeFileName replaces characters that aren't allowed in file names on
+// Windows, macOS or Linux.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// normTarget is the common format every clip is conformed to when re-encoding.
+type normTarget struct {
+	width, height int
+	fps           float64
+	pixFmt        string
+	outMatrix     string // scale filter name of the output color matrix
+	hdr           hdrPlan
+
+	anyAudio   bool // at least one clip has audio
+	mixedAudio bool // some clips have audio and some don't
+	audioCodec string
+	channels   int
+	sampleRate int
+
+	codec OutputCodec
+	enc   EncArgs
+	spec  encodeSpec
+}
+
+// planTarget works out the output format for a re-encode: the frame size and
+// rate chosen by the merge's preset, a single colorspace, the encoder, and the
+// audio layout.
+func (a *App) planTarget(videoFiles []VideoFile, hdr hdrPlan, outputFile string, o mergeOptions) (normTarget, error) {
+	t := normTarget{hdr: hdr}
+
+	// Resolution is already the displayed (rotation-applied) size, which is
+	// what the filters see since ffmpeg auto-rotates on decode.
+	t.width, t.height = o.preset.targetSize(videoFiles)
+	t.fps = o.preset.targetFPS(videoFiles)
+
+	hasAud, noAud := audioMismatch(videoFiles)
+	t.anyAudio = hasAud
+	t.mixedAudio = hasAud && noAud
+	container := outputContainer(outputFile)
+	t.audioCodec = audioCodecFor(container)
+	if o.preset.AudioCodec != "" {
+		if allowed, ok := audioContainerCodecs[container]; ok && !slices.Contains(allowed, o.preset.AudioCodec) {
+			return t, fmt.Errorf("%s can't hold %s audio; choose another container or preset", container, o.preset.AudioCodec)
+		}
+		t.audioCodec = o.preset.AudioCodec
+	}
+	t.channels = o.preset.AudioChannels
+	t.sampleRate = o.preset.AudioSampleRate
+
+	if hdr.tonemapN > 0 {
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("Tone-mapping %d HDR clip(s) to SDR BT.709", hdr.tonemapN),
+		})
+	}
+
+	t.codec = o.codec
+	if t.codec == CodecAuto {
+		t.codec = CodecH264
+	}
+	if hdr.keep && t.codec == CodecH264 {
+		// 10-bit H.264 has almost no player support; HDR needs HEVC or newer
+		t.codec = CodecHEVC
+	}
+	if err := checkContainer(t.codec, outputFile); err != nil {
+		return t, err
+	}
+
+	t.spec = encodeSpec{codec: t.codec, useHW: o.useHW, quality: o.quality, tenBit: hdr.keep, rc: o.rc, keyframes: o.keyframes}
+	if o.rc.Mode == RateTargetSize {
+		kbps, err := targetVideoBitrate(o.rc.TargetSizeMB, totalDuration(videoFiles), t.anyAudio)
+		if err != nil {
+			return t, err
+		}
+		t.spec.rc = RateControl{Mode: RateABR, Bitrate: kbps}
+		t.spec.twoPass = true
+	}
+
+	// Every clip is converted to one colorspace and limited range, and the
+	// output is tagged explicitly so players don't have to guess
+	t.pixFmt = "yuv420p"
+	t.outMatrix = "bt709"
+	t.enc = buildVideoEncoderArgs(t.spec, a.encoders())
+	if hdr.keep {
+		t.pixFmt = "yuv420p10le"
+		t.outMatrix = "bt2020"
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("Keeping HDR: encoding 10-bit %s", strings.ToUpper(string(t.codec))),
+		})
+	}
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": fmt.Sprintf("Using encoder: %s", t.enc.Name),
+	})
+	return t, nil
+}
+
+// colorArgs returns the output color signalling for the given encoder.
+func (t normTarget) colorArgs(encoder string) []string {
+	if t.hdr.keep {
+		return append(hdrColorTags(t.hdr, encoder), "-color_range", "tv")
+	}
+	return []string{"-color_primaries", "bt709", "-color_trc", "bt709", "-colorspace", "bt709", "-color_range", "tv"}
+}
+
+// cpuEncoder returns the software encoder for the target, used when a
+// hardware encoder fails mid-job.
+func (t normTarget) cpuEncoder(have map[string]bool) EncArgs {
+	spec := t.spec
+	spec.useHW = false
+	return buildVideoEncoderArgs(spec, have)
+}
+
+// channelLayout returns the ffmpeg name of the target channel layout.
+func (t normTarget) channelLayout() string {
+	return channelLayouts[t.channels]
+}
+
+// audioArgs returns the audio encoder, sample rate and channel count options.
+func (t normTarget) audioArgs() []string {
+	return []string{"-c:a", t.audioCodec, "-ar", strconv.Itoa(t.sampleRate), "-ac", strconv.Itoa(t.channels)}
+}
+
+// videoFilter returns the filter chain that conforms clip i to the target
+// (tone map + scale + color conversion + pad + fps + SAR).
+func (t normTarget) videoFilter(i int, video VideoFile) string {
+	inColor := clipColor(video)
+	if t.hdr.tonemap[i] {
+		// zscale has already produced BT.709 limited range
+		inColor = colorInfo{matrix: "bt709", rng: "tv", primaries: "bt709"}
+	}
+	vf := fmt.Sprintf(
+		"scale=%d:%d:force_original_aspect_ratio=decrease:%s,setsar=1,format=%s,"+
+			"pad=%d:%d:(ow-iw)/2:(oh-ih)/2,fps=%g",
+		t.width, t.height, colorConvertArgs(inColor, t.outMatrix), t.pixFmt, t.width, t.height, t.fps)
+	if t.hdr.tonemap[i] {
+		vf = tonemapFilter(video) + "," + vf
+	}
+	return vf
+}
+
+// keyframeArgs returns the boundary keyframes for a single encode of the
+// whole timeline with the given encoder. Per-clip normalization needs none:
+// every normalized file starts with a keyframe, so the joins land on one
+// after concat.
+func (t normTarget) keyframeArgs(encoder string, vs []VideoFile) []string {
+	if !t.spec.keyframes.ForceAtJoins {
+		return nil
+	}
+	return joinKeyframeArgs(encoder, vs)
+}
+
+func totalDuration(vs []VideoFile) float64 {
+	var d float64
+	for _, v := range vs {
+		d += v.Duration
+	}
+	return d
+}
+
+// requirements lists the encoders, decoders and filters the re-encode uses.
+// timeline is set for jobs that encode all clips in one filter graph.
+func (t normTarget) requirements(vs []VideoFile, timeline bool) jobRequirements {
+	req := jobRequirements{
+		encoders: []string{t.enc.Name},
+		filters:  []string{"scale", "setsar", "format", "pad", "fps"},
+	}
+	if t.anyAudio {
+		req.encoders = append(req.encoders, t.audioCodec)
+	}
+	for _, v := range vs {
+		if !slices.Contains(req.decoders, v.Codec) {
+			req.decoders = append(req.decoders, v.Codec)
+		}
+	}
+	if t.hdr.tonemapN > 0 {
+		req.filters = append(req.filters, "zscale", "tonemap")
+	}
+	if timeline {
+		req.filters = append(req.filters, "concat")
+		if t.anyAudio {
+			req.filters = append(req.filters, "aresample", "aformat", "anullsrc", "atrim")
+		}
+	} else if t.mixedAudio {
+		req.filters = append(req.filters, "anullsrc")
+	}
+	return req
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"slices"
+	"strconv"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Overlap is footage repeated at a join: the end of one clip recorded again
+// at the start of the next, as some dashcams and stream recorders do.
+type Overlap struct {
+	Join    int     `json:"join"` // 1-based: between clip Join and clip Join+1
+	From    string  `json:"from"`
+	To      string  `json:"to"`
+	Seconds float64 `json:"seconds"`
+	Method  string  `json:"method"` // "audio" or "video"
+	Score   float64 `json:"score"`  // match confidence, 0-1
+}
+
+const (
+	// overlapWindow is how much of each clip edge (seconds) is compared.
+	overlapWindow = 10.0
+	// minOverlap is the shortest picture match reported; shorter ones are noise.
+	minOverlap = 0.5
+	// minAudioOverlap is the shortest sound match reported. Loudness
+	// envelopes are smooth, so short stretches of unrelated sound correlate
+	// easily.
+	minAudioOverlap = 2.0
+	// audioPeakMargin is how far the best envelope match must score above
+	// the best one at any other offset; steady noise or rhythmic sound
+	// matches about as well everywhere.
+	audioPeakMargin = 0.15
+	// minWaveformScore is the sample correlation a match needs. The same
+	// recording decodes to nearly the same waveform even when encoded twice;
+	// different sound with a similar loudness doesn't.
+	minWaveformScore = 0.8
+
+	overlapSampleRate = 8000 // Hz, mono
+	envelopeHop       = 80   // samples per loudness frame (10 ms)
+	overlapFPS        = 15
+	overlapFrameW     = 32
+	overlapFrameH     = 18
+)
+
+// edgeRange returns the part of a clip compared at a join: its last
+// overlapWindow seconds, or its first when head is set.
+func edgeRange(v VideoFile, head bool) (start, dur float64) {
+	dur = min(overlapWindow, v.Duration)
+	if head {
+		return 0, dur
+	}
+	return v.Duration - dur, dur
+}
+
+// decodeEdge runs ffmpeg over a clip edge and returns the raw output.
+func decodeEdge(ctx context.Context, v VideoFile, head bool, output ...string) ([]byte, error) {
+	start, dur := edgeRange(v, head)
+	args := []string{"-hide_banner", "-loglevel", "error", "-ss", formatSeconds(start), "-t", formatSeconds(dur), "-i", v.Path}
+	cmd := exec.CommandContext(ctx, ffmpegBin(), append(args, output...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the edge of %s: %w", v.FileName, err)
+	}
+	return out, nil
+}
+
+// edgeAudio returns a clip edge as mono samples at overlapSampleRate.
+func edgeAudio(ctx context.Context, v VideoFile, head bool) ([]float64, error) {
+	out, err := decodeEdge(ctx, v, head, "-map", "0:a:0", "-ac", "1", "-ar", strconv.Itoa(overlapSampleRate), "-f", "f32le", "-")
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]float64, len(out)/4)
+	for i := range samples {
+		samples[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(out[i*4:])))
+	}
+	return samples, nil
+}
+
+// edgeFrames returns a clip edge as tiny grayscale frames at overlapFPS.
+func edgeFrames(ctx context.Context, v VideoFile, head bool) ([][]byte, error) {
+	vf := fmt.Sprintf("fps=%d,scale=%d:%d:flags=area,format=gray", overlapFPS, overlapFrameW, overlapFrameH)
+	out, err := decodeEdge(ctx, v, head, "-map", "0:v:0", "-vf", vf, "-f", "rawvideo", "-")
+	if err != nil {
+		return nil, err
+	}
+	size := overlapFrameW * overlapFrameH
+	frames := make([][]byte, len(out)/size)
+	for i := range frames {
+		frames[i] = out[i*size : (i+1)*size]
+	}
+	return frames, nil
+}
+
+// pearson is the correlation coefficient of two equally long series; flat
+// series correlate with nothing.
+func pearson(a, b []float64) float64 {
+	n := float64(len(a))
+	var sa, sb, saa, sbb, sab float64
+	for i := range a {
+		sa += a[i]
+		sb += b[i]
+		saa += a[i] * a[i]
+		sbb += b[i] * b[i]
+		sab += a[i] * b[i]
+	}
+	va, vb := saa-sa*sa/n, sbb-sb*sb/n
+	if va <= 1e-12 || vb <= 1e-12 {
+		return 0
+	}
+	return (sab - sa*sb/n) / math.Sqrt(va*vb)
+}
+
+// loudness returns the RMS level of every envelopeHop samples.
+func loudness(samples []float64) []float64 {
+	env := make([]float64, len(samples)/envelopeHop)
+	for i := range env {
+		var sum float64
+		for _, s := range samples[i*envelopeHop : (i+1)*envelopeHop] {
+			sum += s * s
+		}
+		env[i] = math.Sqrt(sum / envelopeHop)
+	}
+	return env
+}
+
+// matchAudio finds where the head of the next clip starts inside the tail
+// of the previous one. The loudness envelopes are compared at every offset;
+// the best one must stand out from all others and then hold up on the
+// samples themselves. It returns the overlap in seconds.
+func matchAudio(tail, head []float64) (overlap, score float64, ok bool) {
+	te, he := loudness(tail), loudness(head)
+	minFrames := int(minAudioOverlap * overlapSampleRate / envelopeHop)
+	var scores []float64
+	best := -1
+	for lag := 0; len(te)-lag >= minFrames; lag++ {
+		n := min(len(te)-lag, len(he))
+		if n < minFrames {
+			break
+		}
+		s := pearson(te[lag:lag+n], he[:n])
+		scores = append(scores, s)
+		if best < 0 || s > scores[best] {
+			best = lag
+		}
+	}
+	if best < 0 || scores[best] < 0.9 {
+		return 0, 0, false
+	}
+	score = scores[best]
+
+	// Neighbouring offsets share most of the window; compare against the
+	// rest, more than 100 ms away
+	runnerUp := -1.0
+	for lag, s := range scores {
+		if abs(lag-best) > 10 && s > runnerUp {
+			runnerUp = s
+		}
+	}
+	if score-runnerUp < audioPeakMargin {
+		return 0, score, false
+	}
+
+	// Refine to the sample within one envelope frame, over up to 2 s
+	at, wave := best*envelopeHop, -2.0
+	for lag := max(0, at-envelopeHop); lag <= best*envelopeHop+envelopeHop && lag < len(tail); lag++ {
+		n := min(len(tail)-lag, len(head), 2*overlapSampleRate)
+		if s := pearson(tail[lag:lag+n], head[:n]); s > wave {
+			at, wave = lag, s
+		}
+	}
+	if wave < minWaveformScore {
+		return 0, score, false
+	}
+	return float64(len(tail)-at) / overlapSampleRate, score, true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// matchFrames is the video counterpart of matchAudio for clips without
+// sound. A match must stand out from the other offsets, or a static scene
+// such as a parked dashcam would match everywhere.
+func matchFrames(tail, head [][]byte) (overlap, score float64, ok bool) {
+	minFrames := int(math.Ceil(minOverlap * overlapFPS))
+	var diffs []float64
+	best, bestDiff := -1, math.Inf(1)
+	for lag := 0; len(tail)-lag >= minFrames; lag++ {
+		n := min(len(tail)-lag, len(head))
+		if n < minFrames {
+			break
+		}
+		var sum int
+		for i := 0; i < n; i++ {
+			for j, p := range tail[lag+i] {
+				d := int(p) - int(head[i][j])
+				if d < 0 {
+					d = -d
+				}
+				sum += d
+			}
+		}
+		diff := float64(sum) / float64(n*overlapFrameW*overlapFrameH)
+		diffs = append(diffs, diff)
+		if diff < bestDiff {
+			best, bestDiff = lag, diff
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	slices.Sort(diffs)
+	median := diffs[len(diffs)/2]
+	if median <= 0 || bestDiff > 3 || bestDiff > 0.35*median {
+		return 0, 0, false
+	}
+	return float64(len(tail)-best) / overlapFPS, 1 - bestDiff/median, true
+}
+
+// detectOverlap compares the end of prev with the start of next, by sound
+// when both have it and by picture otherwise or when the sound is
+// inconclusive (silence, wind noise).
+func detectOverlap(ctx context.Context, prev, next VideoFile) (Overlap, bool, error) {
+	o := Overlap{From: prev.FileName, To: next.FileName}
+	if prev.Duration < minOverlap || next.Duration < minOverlap {
+		return o, false, nil
+	}
+	if prev.HasAudio && next.HasAudio {
+		tail, err := edgeAudio(ctx, prev, false)
+		if err != nil {
+			return o, false, err
+		}
+		head, err := edgeAudio(ctx, next, true)
+		if err != nil {
+			return o, false, err
+		}
+		if sec, score, ok := matchAudio(tail, head); ok {
+			o.Seconds, o.Score, o.Method = sec, score, "audio"
+			return o, true, nil
+		}
+	}
+	tail, err := edgeFrames(ctx, prev, false)
+	if err != nil {
+		return o, false, err
+	}
+	head, err := edgeFrames(ctx, next, true)
+	if err != nil {
+		return o, false, err
+	}
+	if sec, score, ok := matchFrames(tail, head); ok {
+		o.Seconds, o.Score, o.Method = sec, score, "video"
+		return o, true, nil
+	}
+	return o, false, nil
+}
+
+// findOverlaps checks every join for repeated footage and reports each
+// overlap found in the job log. A join that can't be analyzed is left as is.
+func (a *App) findOverlaps(ctx context.Context, vs []VideoFile) ([]Overlap, error) {
+	var overlaps []Overlap
+	for i := 1; i < len(vs); i++ {
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("Checking join %d of %d for repeated footage...", i, len(vs)-1),
+		})
+		o, found, err := detectOverlap(ctx, vs[i-1], vs[i])
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("merge cancelled by user")
+		}
+		if err != nil {
+			log.Printf("[overlap] join %d: %v", i, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		o.Join = i
+		overlaps = append(overlaps, o)
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("Join %d (%s → %s): %.2f s repeated (%s match), trimming the end of %s", i, o.From, o.To, o.Seconds, o.Method, o.From),
+		})
+	}
+	return overlaps, nil
+}
+
+// applyOverlaps cuts each overlap from the end of the earlier clip, which
+// stream copy can do without re-encoding. Whatever the next clip's start
+// trim already removes isn't cut twice.
+func applyOverlaps(vs []VideoFile, overlaps []Overlap) []VideoFile {
+	vs = slices.Clone(vs)
+	for _, o := range overlaps {
+		prev, next := &vs[o.Join-1], vs[o.Join]
+		if cut := o.Seconds - next.TrimStart; cut > prev.TrimEnd {
+			prev.TrimEnd = cut
+		}
+	}
+	return vs
+}
+
+// DetectOverlaps returns the repeated footage at each join of the timeline,
+// without changing the clips.
+func (a *App) DetectOverlaps(videoFiles []VideoFile) ([]Overlap, error) {
+	if a.bins.FFmpegError != "" {
+		return nil, fmt.Errorf("%s", a.bins.FFmpegError)
+	}
+	return a.findOverlaps(a.ctx, videoFiles)
+}
+
+// SetRemoveOverlaps turns automatic removal of repeated footage at the joins on or off.
+func (a *App) SetRemoveOverlaps(remove bool) {
+	a.settings.RemoveOverlaps = remove
+	a.persistSettings()
+}
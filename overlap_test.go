@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const sr = overlapSampleRate
+
+// speech returns noise whose loudness changes every 100 ms, like voices or traffic.
+func speech(r *rand.Rand, seconds float64) []float64 {
+	out := make([]float64, int(seconds*sr))
+	amp := 0.0
+	for i := range out {
+		if i%(sr/10) == 0 {
+			amp = r.Float64()
+		}
+		out[i] = amp * (r.Float64()*2 - 1)
+	}
+	return out
+}
+
+// engine returns steady noise with a slow rhythmic swell, like road or engine noise.
+func engine(r *rand.Rand, seconds float64) []float64 {
+	out := make([]float64, int(seconds*sr))
+	for i := range out {
+		amp := 0.5 + 0.2*math.Sin(2*math.Pi*float64(i)/(sr/2))
+		out[i] = amp * (r.Float64()*2 - 1)
+	}
+	return out
+}
+
+// edges cuts the compared tail of prev and head of next out of a recording
+// that prev covers up to prevEnd and next covers from nextStart.
+func edges(src []float64, prevEnd, nextStart float64) (tail, head []float64) {
+	prev, next := src[:int(prevEnd*sr)], src[int(nextStart*sr):]
+	return prev[len(prev)-int(overlapWindow*sr):], next[:int(overlapWindow*sr)]
+}
+
+func TestMatchAudio(t *testing.T) {
+	tests := []struct {
+		name             string
+		signal           func(*rand.Rand, float64) []float64
+		prevEnd, nextBeg float64
+		reencoded        bool
+		want             float64 // 0 = no overlap
+	}{
+		{"repeated 3 s", speech, 15, 12, false, 3},
+		{"repeated 5 s, encoded twice", speech, 15, 10, true, 5},
+		{"repeated 1 s is too short to trust", speech, 15, 14, false, 0},
+		{"continuous, not repeated", speech, 15, 15, false, 0},
+		{"gap between chunks", speech, 15, 16, false, 0},
+		{"steady engine noise, not repeated", engine, 15, 15, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			tail, head := edges(tt.signal(r, 30), tt.prevEnd, tt.nextBeg)
+			if tt.reencoded {
+				noisy := make([]float64, len(head))
+				for i, s := range head {
+					noisy[i] = s + 0.02*(r.Float64()*2-1)
+				}
+				head = noisy
+			}
+			got, score, ok := matchAudio(tail, head)
+			if tt.want == 0 {
+				if ok {
+					t.Fatalf("found %.3f s overlap (score %.2f), want none", got, score)
+				}
+				return
+			}
+			if !ok || math.Abs(got-tt.want) > 0.01 {
+				t.Fatalf("got %.3f s (ok=%v, score %.2f), want %.3f s", got, ok, score, tt.want)
+			}
+		})
+	}
+}
+
+// frames returns n random tiny frames: a moving scene.
+func frames(r *rand.Rand, n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		f := make([]byte, overlapFrameW*overlapFrameH)
+		for j := range f {
+			f[j] = byte(r.Intn(256))
+		}
+		out[i] = f
+	}
+	return out
+}
+
+func TestMatchFrames(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	scene := frames(r, 600)
+	window := int(overlapWindow * overlapFPS)
+	still := make([][]byte, 600)
+	for i := range still {
+		still[i] = scene[0]
+	}
+
+	tests := []struct {
+		name             string
+		scene            [][]byte
+		prevEnd, nextBeg int // in frames
+		want             float64
+	}{
+		{"repeated 2 s", scene, 300, 270, 2},
+		{"continuous, not repeated", scene, 300, 300, 0},
+		{"static scene matches everywhere", still, 300, 270, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tail := tt.scene[tt.prevEnd-window : tt.prevEnd]
+			head := tt.scene[tt.nextBeg : tt.nextBeg+window]
+			got, _, ok := matchFrames(tail, head)
+			if tt.want == 0 {
+				if ok {
+					t.Fatalf("found %.3f s overlap, want none", got)
+				}
+				return
+			}
+			if !ok || math.Abs(got-tt.want) > 1.0/overlapFPS {
+				t.Fatalf("got %.3f s (ok=%v), want %.3f s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOverlaps(t *testing.T) {
+	vs := []VideoFile{
+		testClip("a.mp4"),
+		testClip("b.mp4", func(v *VideoFile) { v.TrimStart = 1 }),
+		testClip("c.mp4", func(v *VideoFile) { v.TrimStart = 5 }),
+	}
+	got := applyOverlaps(vs, []Overlap{{Join: 1, Seconds: 3}, {Join: 2, Seconds: 3}})
+	if got[0].TrimEnd != 2 {
+		t.Errorf("a.mp4 TrimEnd = %v, want 2 (3 s overlap minus b's 1 s start trim)", got[0].TrimEnd)
+	}
+	if got[1].TrimEnd != 0 {
+		t.Errorf("b.mp4 TrimEnd = %v, want 0 (c's start trim already removes the overlap)", got[1].TrimEnd)
+	}
+	if vs[0].TrimEnd != 0 {
+		t.Error("applyOverlaps modified its input")
+	}
+}
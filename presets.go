@@ -0,0 +1,448 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// presetsVersion is the schema version of presets.json and exported preset files.
+const presetsVersion = 1
+
+// Resolution policies: how the output frame size is chosen.
+const (
+	ResolutionLargest = "largest" // the widest clip
+	ResolutionFirst   = "first"   // the first clip in the timeline
+	ResolutionFixed   = "fixed"   // the preset's Width x Height
+)
+
+// Frame rate policies.
+const (
+	FPSFixed   = "fixed"   // the preset's FPS
+	FPSFirst   = "first"   // the first clip's rate
+	FPSHighest = "highest" // the fastest clip's rate
+)
+
+// normalizationFPS is the frame rate used when a preset doesn't set one.
+const normalizationFPS = 30
+
+// audioContainerCodecs lists the audio encoders each output container accepts.
+var audioContainerCodecs = map[string][]string{
+	"mp4":  {"aac", "libopus", "libmp3lame", "ac3", "flac"},
+	"mov":  {"aac", "ac3", "pcm_s16le"},
+	"mkv":  {"aac", "libopus", "libvorbis", "libmp3lame", "ac3", "flac", "pcm_s16le"},
+	"webm": {"libopus", "libvorbis"},
+	"ts":   {"aac", "ac3", "libmp3lame"},
+}
+
+// channelLayouts maps supported channel counts to ffmpeg layout names.
+var channelLayouts = map[int]string{1: "mono", 2: "stereo", 6: "5.1", 8: "7.1"}
+
+// builtInPresets are always available and can't be changed or deleted. Their
+// levels keep the CRFs they had before the unified quality scale.
+var builtInPresets = []MergePreset{
+	{Name: "Fast Copy (Same Codec/Res)", Format: "copy", Quality: DefaultQuality},
+	{Name: "MP4 (H.264) - High Quality", Format: "mp4", VideoCodec: CodecH264, Quality: qualityLevel("libx264", 18)},
+	{Name: "MP4 (H.264) - Medium Quality", Format: "mp4", VideoCodec: CodecH264, Quality: qualityLevel("libx264", 23)},
+	{Name: "WebM (VP9) - Medium Quality", Format: "webm", VideoCodec: CodecVP9, Quality: qualityLevel("libvpx-vp9", 28), AudioCodec: "libopus"},
+}
+
+// withDefaults fills the fields a preset may leave empty.
+func (p MergePreset) withDefaults() MergePreset {
+	if p.RateControl.Mode == "" {
+		p.RateControl.Mode = RateQuality
+	}
+	if p.ResolutionPolicy == "" {
+		p.ResolutionPolicy = ResolutionLargest
+	}
+	if p.FPSPolicy == "" {
+		p.FPSPolicy = FPSFixed
+	}
+	if p.AudioChannels == 0 {
+		p.AudioChannels = 2
+	}
+	if p.AudioSampleRate == 0 {
+		p.AudioSampleRate = 48000
+	}
+	return p
+}
+
+// validate checks a preset for internal consistency and, when the ffmpeg
+// capabilities are known, that the build has the encoders it needs.
+func (p MergePreset) validate(caps *FFmpegCapabilities, encAvail map[string]bool) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("preset name is required")
+	}
+	if p.Format != "copy" {
+		if _, ok := containerCodecs[p.Format]; !ok {
+			return fmt.Errorf("unknown container %q", p.Format)
+		}
+	}
+	switch p.VideoCodec {
+	case CodecAuto:
+	case CodecH264, CodecHEVC, CodecAV1, CodecVP9:
+		if p.Format != "copy" && !slices.Contains(containerCodecs[p.Format], p.VideoCodec) {
+			return fmt.Errorf("%s can't hold %s video", p.Format, strings.ToUpper(string(p.VideoCodec)))
+		}
+	default:
+		return fmt.Errorf("unknown video codec %q", p.VideoCodec)
+	}
+	if p.Quality < 0 || p.Quality > 100 {
+		return fmt.Errorf("quality must be between 0 and 100, got %d", p.Quality)
+	}
+	if err := p.RateControl.validate(); err != nil {
+		return err
+	}
+	switch p.ResolutionPolicy {
+	case ResolutionLargest, ResolutionFirst:
+	case ResolutionFixed:
+		if p.Width <= 0 || p.Height <= 0 || p.Width%2 != 0 || p.Height%2 != 0 {
+			return fmt.Errorf("fixed resolution must be positive and even, got %dx%d", p.Width, p.Height)
+		}
+	default:
+		return fmt.Errorf("unknown resolution policy %q", p.ResolutionPolicy)
+	}
+	switch p.FPSPolicy {
+	case FPSFirst, FPSHighest:
+	case FPSFixed:
+		if p.FPS < 0 || p.FPS > 240 {
+			return fmt.Errorf("frame rate must be between 0 and 240, got %g", p.FPS)
+		}
+	default:
+		return fmt.Errorf("unknown frame rate policy %q", p.FPSPolicy)
+	}
+	if _, ok := channelLayouts[p.AudioChannels]; !ok {
+		return fmt.Errorf("unsupported audio channel count %d (use 1, 2, 6 or 8)", p.AudioChannels)
+	}
+	if p.AudioSampleRate < 8000 || p.AudioSampleRate > 192000 {
+		return fmt.Errorf("audio sample rate must be between 8000 and 192000 Hz, got %d", p.AudioSampleRate)
+	}
+	if p.AudioCodec != "" && p.Format != "copy" && !slices.Contains(audioContainerCodecs[p.Format], p.AudioCodec) {
+		return fmt.Errorf("%s can't hold %s audio", p.Format, p.AudioCodec)
+	}
+
+	if caps == nil {
+		return nil
+	}
+	if p.AudioCodec != "" && !caps.hasEncoder(p.AudioCodec) {
+		return fmt.Errorf("this ffmpeg build has no %s audio encoder", p.AudioCodec)
+	}
+	if p.VideoCodec != CodecAuto {
+		usable := slices.ContainsFunc(append(slices.Clone(swEncoders[p.VideoCodec]), hwEncoders[p.VideoCodec]...), func(name string) bool {
+			return encAvail[name]
+		})
+		if !usable {
+			return fmt.Errorf("this ffmpeg build has no usable %s encoder", strings.ToUpper(string(p.VideoCodec)))
+		}
+	}
+	return nil
+}
+
+// targetSize picks the output frame size for the clips.
+func (p MergePreset) targetSize(vs []VideoFile) (int, int) {
+	if p.ResolutionPolicy == ResolutionFixed {
+		return p.Width, p.Height
+	}
+	var width, height int
+	for i, v := range vs {
+		var w, h int
+		fmt.Sscanf(v.Resolution, "%dx%d", &w, &h)
+		if p.ResolutionPolicy == ResolutionFirst {
+			if i == 0 {
+				width, height = w, h
+			}
+			continue
+		}
+		if w > width {
+			width, height = w, h
+		}
+	}
+	return width, height
+}
+
+// targetFPS picks the output frame rate for the clips.
+func (p MergePreset) targetFPS(vs []VideoFile) float64 {
+	fps := 0.0
+	switch p.FPSPolicy {
+	case FPSFirst:
+		if len(vs) > 0 {
+			fps = vs[0].FPS
+		}
+	case FPSHighest:
+		for _, v := range vs {
+			fps = math.Max(fps, v.FPS)
+		}
+	default:
+		fps = p.FPS
+	}
+	if fps <= 0 {
+		return normalizationFPS
+	}
+	return fps
+}
+
+// allowsCopy reports whether stream-copying the clips still honours an
+// explicitly fixed resolution or frame rate.
+func (p MergePreset) allowsCopy(vs []VideoFile) bool {
+	if len(vs) == 0 {
+		return false
+	}
+	if p.ResolutionPolicy == ResolutionFixed && vs[0].Resolution != fmt.Sprintf("%dx%d", p.Width, p.Height) {
+		return false
+	}
+	if p.FPSPolicy == FPSFixed && p.FPS > 0 && math.Abs(vs[0].FPS-p.FPS) > 0.05 {
+		return false
+	}
+	return true
+}
+
+// presetFile is the on-disk format of presets.json and exported presets.
+type presetFile struct {
+	Version int           `json:"version"`
+	Presets []MergePreset `json:"presets"`
+}
+
+func presetsPath() (string, error) {
+	return configPath("presets.json")
+}
+
+// readPresetFile parses a preset file, rejecting versions newer than this app.
+func readPresetFile(path string) ([]MergePreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f presetFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if f.Version > presetsVersion {
+		return nil, fmt.Errorf("%s has preset format version %d, newer than this app supports (%d)", path, f.Version, presetsVersion)
+	}
+	for i := range f.Presets {
+		f.Presets[i].BuiltIn = false
+		f.Presets[i] = f.Presets[i].withDefaults()
+	}
+	return f.Presets, nil
+}
+
+// loadUserPresets reads the stored user presets; a missing file means none.
+func loadUserPresets() ([]MergePreset, error) {
+	path, err := presetsPath()
+	if err != nil {
+		return nil, err
+	}
+	presets, err := readPresetFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return presets, err
+}
+
+var presetsMu sync.Mutex
+
+func saveUserPresets(presets []MergePreset) error {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+	path, err := presetsPath()
+	if err != nil {
+		return err
+	}
+	return writeJSONAtomic(path, presetFile{Version: presetsVersion, Presets: presets})
+}
+
+// findPreset looks a preset up by name, built-ins first.
+func (a *App) findPreset(name string) (MergePreset, bool) {
+	for _, p := range a.GetPresets() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return MergePreset{}, false
+}
+
+// userPresetIndex returns the position of a user preset, or -1.
+func (a *App) userPresetIndex(name string) int {
+	return slices.IndexFunc(a.userPresets, func(p MergePreset) bool { return p.Name == name })
+}
+
+// GetPresets returns the built-in presets followed by the user's own.
+func (a *App) GetPresets() []MergePreset {
+	presets := make([]MergePreset, 0, len(builtInPresets)+len(a.userPresets))
+	for _, p := range builtInPresets {
+		p = p.withDefaults()
+		p.BuiltIn = true
+		presets = append(presets, p)
+	}
+	return append(presets, a.userPresets...)
+}
+
+// CreatePreset validates and stores a new user preset.
+func (a *App) CreatePreset(p MergePreset) (MergePreset, error) {
+	p = p.withDefaults()
+	p.Name = strings.TrimSpace(p.Name)
+	p.BuiltIn = false
+	if err := p.validate(a.caps, a.encoders()); err != nil {
+		return p, err
+	}
+	if _, exists := a.findPreset(p.Name); exists {
+		return p, fmt.Errorf("a preset named %q already exists", p.Name)
+	}
+	presets := append(slices.Clone(a.userPresets), p)
+	if err := saveUserPresets(presets); err != nil {
+		return p, fmt.Errorf("failed to save presets: %w", err)
+	}
+	a.userPresets = presets
+	return p, nil
+}
+
+// UpdatePreset replaces the user preset called name; p may rename it.
+func (a *App) UpdatePreset(name string, p MergePreset) (MergePreset, error) {
+	i := a.userPresetIndex(name)
+	if i < 0 {
+		if _, builtIn := a.findPreset(name); builtIn {
+			return p, fmt.Errorf("built-in preset %q can't be changed; save a copy instead", name)
+		}
+		return p, fmt.Errorf("no preset named %q", name)
+	}
+	p = p.withDefaults()
+	p.Name = strings.TrimSpace(p.Name)
+	p.BuiltIn = false
+	if err := p.validate(a.caps, a.encoders()); err != nil {
+		return p, err
+	}
+	if p.Name != name {
+		if _, exists := a.findPreset(p.Name); exists {
+			return p, fmt.Errorf("a preset named %q already exists", p.Name)
+		}
+	}
+	presets := slices.Clone(a.userPresets)
+	presets[i] = p
+	if err := saveUserPresets(presets); err != nil {
+		return p, fmt.Errorf("failed to save presets: %w", err)
+	}
+	a.userPresets = presets
+	if p.Name != name && a.settings.DefaultPreset == name {
+		a.settings.DefaultPreset = p.Name
+		a.persistSettings()
+	}
+	return p, nil
+}
+
+// DeletePreset removes a user preset.
+func (a *App) DeletePreset(name string) error {
+	i := a.userPresetIndex(name)
+	if i < 0 {
+		return fmt.Errorf("no user preset named %q", name)
+	}
+	presets := slices.Delete(slices.Clone(a.userPresets), i, i+1)
+	if err := saveUserPresets(presets); err != nil {
+		return fmt.Errorf("failed to save presets: %w", err)
+	}
+	a.userPresets = presets
+	if a.settings.DefaultPreset == name {
+		a.settings.DefaultPreset = ""
+		a.persistSettings()
+	}
+	return nil
+}
+
+// ExportPresets writes the named user presets (all of them when names is
+// empty) to a file chosen by the user and returns its path.
+func (a *App) ExportPresets(names []string) (string, error) {
+	var out []MergePreset
+	for _, p := range a.userPresets {
+		if len(names) == 0 || slices.Contains(names, p.Name) {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return "", fmt.Errorf("no user presets to export")
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Presets",
+		DefaultFilename: "stitcher-presets.json",
+		Filters:         []runtime.FileFilter{{DisplayName: "Preset files (*.json)", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if err := writeJSONAtomic(path, presetFile{Version: presetsVersion, Presets: out}); err != nil {
+		return "", fmt.Errorf("failed to export presets: %w", err)
+	}
+	return path, nil
+}
+
+// ImportPresets reads presets from a file chosen by the user. Presets whose
+// names are taken get a numbered suffix; invalid ones are skipped and reported.
+func (a *App) ImportPresets() ([]MergePreset, error) {
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Import Presets",
+		Filters: []runtime.FileFilter{{DisplayName: "Preset files (*.json)", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return nil, err
+	}
+	incoming, err := readPresetFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	presets := slices.Clone(a.userPresets)
+	var imported []MergePreset
+	var skipped []string
+	for _, p := range incoming {
+		p.Name = strings.TrimSpace(p.Name)
+		if err := p.validate(a.caps, a.encoders()); err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", p.Name, err))
+			continue
+		}
+		base := p.Name
+		taken := func(name string) bool {
+			_, builtIn := a.findPreset(name)
+			return builtIn || slices.ContainsFunc(presets, func(q MergePreset) bool { return q.Name == name })
+		}
+		for n := 2; taken(p.Name); n++ {
+			p.Name = fmt.Sprintf("%s (%d)", base, n)
+		}
+		presets = append(presets, p)
+		imported = append(imported, p)
+	}
+	if len(imported) > 0 {
+		if err := saveUserPresets(presets); err != nil {
+			return nil, fmt.Errorf("failed to save presets: %w", err)
+		}
+		a.userPresets = presets
+	}
+	if len(skipped) > 0 {
+		log.Printf("[presets] skipped on import: %s", strings.Join(skipped, "; "))
+		return imported, fmt.Errorf("imported %d preset(s), skipped %d: %s", len(imported), len(skipped), strings.Join(skipped, "; "))
+	}
+	return imported, nil
+}
+
+// ApplyPreset makes a preset the active output configuration: its codec,
+// quality and rate control replace the current ones, and its container,
+// resolution, frame rate and audio settings shape the next merges.
+func (a *App) ApplyPreset(name string) error {
+	p, ok := a.findPreset(name)
+	if !ok {
+		return fmt.Errorf("no preset named %q", name)
+	}
+	if err := p.validate(a.caps, a.encoders()); err != nil {
+		return fmt.Errorf("preset %q can't be used: %w", name, err)
+	}
+	a.preset = p
+	a.codec = p.VideoCodec
+	a.quality = p.Quality
+	a.rc = p.RateControl
+	a.persistSettings()
+	return nil
+}
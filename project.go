@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// projectVersion is the schema version of .stitch project files.
+const projectVersion = 1
+
+// projectExt is the extension of project files.
+const projectExt = ".stitch"
+
+// Project is a saved merge setup: the clips in timeline order with their
+// metadata, the preset and the output/encoder settings.
+type Project struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+
+	Clips []VideoFile `json:"clips"` // timeline order; thumbnails aren't stored
+
+	Preset      MergePreset     `json:"preset"`
+	OutputPath  string          `json:"outputPath"` // suggested output file; "" = ask
+	Codec       OutputCodec     `json:"codec"`
+	Quality     int             `json:"quality"`
+	RateControl RateControl     `json:"rateControl"`
+	Keyframes   KeyframeOptions `json:"keyframes"`
+	HDRMode     HDRMode         `json:"hdrMode"`
+}
+
+// RelinkedClip records a clip found at a new location.
+type RelinkedClip struct {
+	OldPath string `json:"oldPath"`
+	NewPath string `json:"newPath"`
+}
+
+// OpenedProject is the result of OpenProject.
+type OpenedProject struct {
+	Path     string         `json:"path"`
+	Project  Project        `json:"project"`
+	Relinked []RelinkedClip `json:"relinked"`
+	Missing  []string       `json:"missing"` // paths that couldn't be found; those clips stay in the list
+}
+
+// SaveProject writes the clips and the current output settings to a
+// .stitch file chosen by the user and returns its path.
+func (a *App) SaveProject(clips []VideoFile, outputPath string) (string, error) {
+	defaultName := "Untitled" + projectExt
+	defaultDir := a.settings.LastInputDir
+	if a.projectPath != "" {
+		defaultName = filepath.Base(a.projectPath)
+		defaultDir = filepath.Dir(a.projectPath)
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:            "Save Project",
+		DefaultDirectory: defaultDir,
+		DefaultFilename:  defaultName,
+		Filters:          []runtime.FileFilter{{DisplayName: "Stitcher projects (*.stitch)", Pattern: "*" + projectExt}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if filepath.Ext(path) == "" {
+		path += projectExt
+	}
+
+	p := Project{
+		Version:     projectVersion,
+		Name:        strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Clips:       make([]VideoFile, len(clips)),
+		Preset:      a.preset,
+		OutputPath:  outputPath,
+		Codec:       a.codec,
+		Quality:     a.quality,
+		RateControl: a.rc,
+		Keyframes:   a.keyframes,
+		HDRMode:     a.hdrMode,
+	}
+	for i, c := range clips {
+		c.ThumbnailBase64 = ""
+		p.Clips[i] = c
+	}
+	if err := writeJSONAtomic(path, p); err != nil {
+		return "", fmt.Errorf("failed to save project: %w", err)
+	}
+	a.projectPath = path
+	a.setOutputHint(outputPath, clips)
+	return path, nil
+}
+
+// NewProject forgets the open project, so merges no longer default to its
+// output file.
+func (a *App) NewProject() {
+	a.projectPath = ""
+	a.setOutputHint("", nil)
+}
+
+// setOutputHint remembers the output file a project suggests for its clips.
+func (a *App) setOutputHint(path string, clips []VideoFile) {
+	a.outputHint, a.hintClips = path, nil
+	if path == "" {
+		return
+	}
+	for _, c := range clips {
+		a.hintClips = append(a.hintClips, c.Path)
+	}
+}
+
+// projectOutput returns the output file the open project suggests for a
+// merge of videoFiles. Once the clip list differs from the project's the
+// user has moved on, and the suggestion is dropped.
+func (a *App) projectOutput(videoFiles []VideoFile) string {
+	if a.outputHint == "" {
+		return ""
+	}
+	if !slices.EqualFunc(videoFiles, a.hintClips, func(v VideoFile, path string) bool { return v.Path == path }) {
+		a.setOutputHint("", nil)
+		return ""
+	}
+	return a.outputHint
+}
+
+// OpenProject loads a .stitch file chosen by the user, relinks clips that
+// have moved, re-reads their metadata and makes the project's settings current.
+func (a *App) OpenProject() (*OpenedProject, error) {
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Open Project",
+		Filters: []runtime.FileFilter{{DisplayName: "Stitcher projects (*.stitch)", Pattern: "*" + projectExt}},
+	})
+	if err != nil || path == "" {
+		return nil, err
+	}
+	p, err := readProject(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OpenedProject{Path: path}
+	roots := []string{filepath.Dir(path)}
+	for i, c := range p.Clips {
+		if _, err := os.Stat(c.Path); err != nil {
+			found := relinkClip(c, roots)
+			if found == "" {
+				result.Missing = append(result.Missing, c.Path)
+				continue
+			}
+			result.Relinked = append(result.Relinked, RelinkedClip{OldPath: c.Path, NewPath: found})
+			c.Path = found
+			c.FileName = filepath.Base(found)
+		}
+		// Clips of one project usually moved together, so look next to the
+		// ones already found first
+		if dir := filepath.Dir(c.Path); !slices.Contains(roots, dir) {
+			roots = append(roots, dir)
+		}
+		if fresh, err := a.GetVideoMetadata(c.Path); err == nil {
+			fresh.TrimStart, fresh.TrimEnd = c.TrimStart, c.TrimEnd
+			c = fresh
+		} else {
+			log.Printf("[project] keeping stored metadata for %s: %v", c.Path, err)
+		}
+		p.Clips[i] = c
+	}
+
+	if err := a.applyProject(p); err != nil {
+		return nil, err
+	}
+	a.projectPath = path
+	result.Project = p
+	return result, nil
+}
+
+// readProject parses a project file, rejecting versions newer than this app.
+func readProject(path string) (Project, error) {
+	var p Project
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("failed to parse project %s: %w", path, err)
+	}
+	if p.Version > projectVersion {
+		return p, fmt.Errorf("%s was saved by a newer version of Stitcher (project format %d, supported %d)", path, p.Version, projectVersion)
+	}
+	p.Version = projectVersion
+	p.Preset = p.Preset.withDefaults()
+	if p.RateControl.Mode == "" {
+		p.RateControl.Mode = RateQuality
+	}
+	if p.HDRMode == "" {
+		p.HDRMode = HDRModeTonemap
+	}
+	return p, nil
+}
+
+// applyProject makes a project's preset and encoder settings current.
+func (a *App) applyProject(p Project) error {
+	s := a.snapshotSettings()
+	s.OutputCodec = string(p.Codec)
+	s.Quality = p.Quality
+	s.RateControl = p.RateControl
+	s.Keyframes = p.Keyframes
+	s.HDRMode = string(p.HDRMode)
+	if err := s.validate(); err != nil {
+		return fmt.Errorf("project settings are invalid: %w", err)
+	}
+	if err := p.Preset.validate(a.caps, a.encoders()); err != nil {
+		return fmt.Errorf("project preset can't be used: %w", err)
+	}
+	a.applySettings(s)
+	a.preset = p.Preset
+	a.setOutputHint(p.OutputPath, p.Clips)
+	return nil
+}
+
+// relinkSearchLimit caps the directory entries visited per missing clip so a
+// project next to a huge tree doesn't stall opening.
+const relinkSearchLimit = 20000
+
+// relinkClip looks for a moved clip by file name and size: near its old
+// location (its folder and up to two parents) and under the given roots,
+// each searched two levels deep.
+func relinkClip(c VideoFile, roots []string) string {
+	name := filepath.Base(c.Path)
+	var dirs []string
+	dir := filepath.Dir(c.Path)
+	for i := 0; i < 3; i++ {
+		dirs = append(dirs, dir)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	dirs = append(dirs, roots...)
+
+	for _, root := range dirs {
+		if found := searchDir(root, name, c.Size, 2); found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// searchDir walks root up to depth levels looking for a file with the given
+// name and, when size is known, the same size.
+func searchDir(root, name string, size int64, depth int) string {
+	if _, err := os.Stat(root); err != nil {
+		return ""
+	}
+	baseDepth := strings.Count(filepath.Clean(root), string(filepath.Separator))
+	visited := 0
+	found := ""
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		visited++
+		if visited > relinkSearchLimit {
+			return filepath.SkipAll
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || strings.Count(path, string(filepath.Separator))-baseDepth > depth) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(d.Name(), name) {
+			return nil
+		}
+		if size > 0 {
+			info, err := d.Info()
+			if err != nil || info.Size() != size {
+				return nil
+			}
+		}
+		found = path
+		return filepath.SkipAll
+	})
+	return found
+}
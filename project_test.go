@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputHint(t *testing.T) {
+	a := &App{}
+	a.applySettings(defaultSettings())
+	clips := []VideoFile{testClip("a.mp4"), testClip("b.mp4")}
+	a.setOutputHint("/out/Holiday.mp4", clips)
+
+	if got := a.outputName(clips, "webm"); got != "Holiday.webm" {
+		t.Errorf("outputName = %q, want the project's name with the current extension", got)
+	}
+	if got := a.outputDir(clips); got != "/out" {
+		t.Errorf("outputDir = %q, want the project's folder", got)
+	}
+
+	other := []VideoFile{testClip("c.mp4"), testClip("d.mp4")}
+	if got := a.outputName(other, "mp4"); got == "Holiday.mp4" {
+		t.Error("unrelated clips got the project's output name")
+	}
+	if got := a.outputName(clips, "mp4"); got == "Holiday.mp4" {
+		t.Error("the suggestion survived a change of the clip list")
+	}
+
+	a.setOutputHint("/out/Holiday.mp4", clips)
+	a.NewProject()
+	if got := a.outputDir(clips); got == "/out" {
+		t.Error("the suggestion survived starting a new project")
+	}
+}
+
+func TestRelinkClip(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string, size int) string {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	moved := write("project/footage/day1/clip.mp4", 10)
+	write("project/other/clip.mp4", 5)
+	write("project/.cache/x/clip2.mp4", 7)
+	tests := []struct {
+		name  string
+		path  string
+		size  int64
+		roots []string
+		want  string
+	}{
+		{"next to its old folder", filepath.Join(root, "project/footage/old/clip.mp4"), 10, nil, moved},
+		{"matched by size", filepath.Join(root, "gone/clip.mp4"), 10, []string{filepath.Join(root, "project")}, moved},
+		{"case-insensitive name", filepath.Join(root, "gone/CLIP.MP4"), 10, []string{filepath.Join(root, "project")}, moved},
+		{"size differs", filepath.Join(root, "gone/clip.mp4"), 3, []string{filepath.Join(root, "project")}, ""},
+		{"hidden folders skipped", filepath.Join(root, "gone/clip2.mp4"), 7, []string{filepath.Join(root, "project")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testClip("clip.mp4", func(v *VideoFile) { v.Path, v.Size = tt.path, tt.size })
+			if got := relinkClip(c, tt.roots); got != tt.want {
+				t.Errorf("relinkClip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
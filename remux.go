@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// wrapperProperties are copy-check parameters that belong to the container
+// rather than the streams: MP4 and MOV pick a per-track time base, MKV uses
+// 1/1000 and MPEG-TS 1/90000, so rewrapping every clip into one container
+// makes them agree.
+var wrapperProperties = []string{"time base"}
+
+// streamIncompatibilities drops the differences a remux of vs resolves.
+// Differing codec configuration is only resolved by MPEG-TS, which carries
+// the parameter sets in-band before every keyframe. Matroska keeps them in
+// the header (CodecPrivate), where the concat demuxer keeps only the first
+// clip's, so those clips still need conforming or re-encoding.
+func streamIncompatibilities(vs []VideoFile, reasons []CopyIncompatibility) []CopyIncompatibility {
+	inBand := remuxContainer(vs) == "ts"
+	return slices.DeleteFunc(slices.Clone(reasons), func(r CopyIncompatibility) bool {
+		return slices.Contains(wrapperProperties, r.Property) || (inBand && r.Property == "codec configuration")
+	})
+}
+
+// remuxable reports whether the clips can be joined by stream copy once rewrapped.
+func remuxable(vs []VideoFile) bool {
+	return len(streamIncompatibilities(vs, copyIncompatibilities(vs))) == 0
+}
+
+// sameContainer reports whether all clips share one container format.
+func sameContainer(vs []VideoFile) bool {
+	for _, v := range vs[1:] {
+		if v.Container != vs[0].Container {
+			return false
+		}
+	}
+	return true
+}
+
+// tsVideoCodecs and tsAudioCodecs can be carried in MPEG-TS without loss.
+var (
+	tsVideoCodecs = []string{"h264", "hevc", "mpeg2video"}
+	tsAudioCodecs = []string{"aac", "mp3", "ac3", "eac3", "mp2"}
+)
+
+// remuxContainer picks the common wrapper for a remux: MPEG-TS, whose
+// in-band headers and fixed 90 kHz clock make joins robust, when it can
+// carry the streams, and Matroska otherwise. MPEG-TS has no place for a
+// display rotation, so rotated clips always go to Matroska.
+func remuxContainer(vs []VideoFile) string {
+	for _, v := range vs {
+		if v.Rotation != 0 || !slices.Contains(tsVideoCodecs, v.Codec) {
+			return "mkv"
+		}
+		if v.HasAudio && !slices.Contains(tsAudioCodecs, v.AudioCodec) {
+			return "mkv"
+		}
+	}
+	return "ts"
+}
+
+// remuxArgs rewraps one clip's first video and audio streams without
+// re-encoding. MP4/MOV/MKV store H.264/HEVC length-prefixed with the
+// parameter sets in the header; MPEG-TS needs Annex B with them in-band.
+// Timestamps are shifted to start at zero so the concat demuxer lines
+// the clips up back to back.
+func remuxArgs(v VideoFile, container, output string) []string {
+	args := append([]string{"-y", "-hide_banner", "-loglevel", "error", "-fflags", "+genpts"}, v.inputArgs()...)
+	args = append(args, "-map", "0:v:0", "-map", "0:a:0?", "-c", "copy", "-sn", "-dn", "-map_metadata", "-1", "-map_chapters", "-1")
+	if container == "ts" && v.Container != "mpegts" {
+		switch v.Codec {
+		case "h264":
+			args = append(args, "-bsf:v", "h264_mp4toannexb")
+		case "hevc":
+			args = append(args, "-bsf:v", "hevc_mp4toannexb")
+		}
+	}
+	args = append(args, "-avoid_negative_ts", "make_zero")
+	if container == "ts" {
+		args = append(args, "-f", "mpegts", "-muxdelay", "0", "-muxpreload", "0")
+	}
+	return append(args, output)
+}
+
+// joinRemuxedArgs joins rewrapped clips into the output container. AAC in
+// MPEG-TS is ADTS framed, which MP4 and MOV need converted back. The clips'
+// display rotation, which the rewrap doesn't keep, is set on the output
+// again; see rotationArgs.
+func joinRemuxedArgs(vs []VideoFile, container, listFile, outputFile string, displayRotation bool) []string {
+	input, output := rotationArgs(vs[0].Rotation, displayRotation)
+	args := append([]string{"-y"}, input...)
+	args = append(args, "-f", "concat", "-safe", "0", "-i", listFile, "-map", "0:v:0", "-map", "0:a:0?", "-c", "copy")
+	args = append(args, output...)
+	if c := outputContainer(outputFile); container == "ts" && vs[0].AudioCodec == "aac" && (c == "mp4" || c == "mov") {
+		args = append(args, "-bsf:a", "aac_adtstoasc")
+	}
+	args = append(args, codecTagArgs(vs[0].Codec, outputFile)...)
+	return append(args, outputFile)
+}
+
+// rotationArgs tags a stream copy with a clockwise display rotation: as
+// the counter-clockwise -display_rotation input option on ffmpeg 6 and
+// later, and as the rotate tag older releases write instead.
+func rotationArgs(rotation int, displayRotation bool) (input, output []string) {
+	switch {
+	case rotation == 0:
+		return nil, nil
+	case displayRotation:
+		return []string{"-display_rotation:v:0", strconv.Itoa(-rotation)}, nil
+	}
+	return nil, []string{"-metadata:s:v:0", "rotate=" + strconv.Itoa(rotation)}
+}
+
+// remuxMerge rewraps every clip into a common container and joins them by
+// stream copy, so identical streams in different wrappers are never
+// re-encoded. Rewrapping reports the first half of the progress, the join
+// the second.
+func (a *App) remuxMerge(ctx context.Context, vs []VideoFile, outputFile string) error {
+	container := remuxContainer(vs)
+	tempDir, err := os.MkdirTemp(a.tempDir(), "stitcher-remux-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for remuxing: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	total := totalDuration(vs)
+	// Weigh clips by duration; without durations each clip counts the same
+	weight := func(v VideoFile) float64 { return v.Duration / total }
+	if total <= 0 {
+		weight = func(VideoFile) float64 { return 1 / float64(len(vs)) }
+	}
+	paths := make([]string, len(vs))
+	var done float64
+	for i, v := range vs {
+		out := filepath.Join(tempDir, fmt.Sprintf("remux-%d.%s", i, container))
+		from, to := 50*done, 50*(done+weight(v))
+		if err := a.runFFmpegProgress(ctx, remuxArgs(v, container, out), v.Duration, fmt.Sprintf("Rewrapping %s...", v.FileName), from, to); err != nil {
+			return fmt.Errorf("failed to rewrap %s: %w", v.FileName, err)
+		}
+		done += weight(v)
+		paths[i] = out
+	}
+
+	listFile, err := writeConcatList(paths)
+	if err != nil {
+		return fmt.Errorf("failed to write concat list: %w", err)
+	}
+	defer os.Remove(listFile)
+	return a.runFFmpegProgress(ctx, joinRemuxedArgs(vs, container, listFile, outputFile, a.caps.hasDisplayRotation()), total, "Merging...", 50, 100)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRemuxContainer(t *testing.T) {
+	tests := []struct {
+		name string
+		vs   []VideoFile
+		want string
+	}{
+		{"h264 aac", []VideoFile{testClip("a.mp4")}, "ts"},
+		{"hevc silent", []VideoFile{testClip("a.mp4", func(v *VideoFile) { v.Codec, v.HasAudio = "hevc", false })}, "ts"},
+		{"vp9", []VideoFile{testClip("a.mp4"), testClip("b.webm", func(v *VideoFile) { v.Codec = "vp9" })}, "mkv"},
+		{"camera LPCM", []VideoFile{testClip("a.mov", func(v *VideoFile) { v.AudioCodec = "pcm_s16le" })}, "mkv"},
+		{"opus", []VideoFile{testClip("a.mkv", func(v *VideoFile) { v.AudioCodec = "opus" })}, "mkv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remuxContainer(tt.vs); got != tt.want {
+				t.Errorf("remuxContainer = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemuxContainerRotated(t *testing.T) {
+	for _, rotation := range []int{90, 180, 270} {
+		rotated := func(v *VideoFile) { v.Rotation = rotation }
+		vs := []VideoFile{testClip("a.mp4", rotated), testClip("b.mp4", rotated)}
+		if got := remuxContainer(vs); got == "ts" {
+			t.Errorf("clips rotated %d° rewrapped to MPEG-TS, which drops the rotation", rotation)
+		}
+	}
+}
+
+func TestJoinRemuxedArgsRotation(t *testing.T) {
+	vs := []VideoFile{testClip("a.mp4", func(v *VideoFile) { v.Rotation = 90 })}
+	args := joinRemuxedArgs(vs, "mkv", "list.txt", "out.mp4", true)
+	i := slices.Index(args, "-display_rotation:v:0")
+	if i < 0 || args[i+1] != "-90" || i > slices.Index(args, "-i") {
+		t.Errorf("ffmpeg 6+: args %q don't set -display_rotation -90 on the input", args)
+	}
+	args = joinRemuxedArgs(vs, "mkv", "list.txt", "out.mp4", false)
+	if i := slices.Index(args, "-metadata:s:v:0"); i < 0 || args[i+1] != "rotate=90" {
+		t.Errorf("older ffmpeg: args %q don't set the rotate tag", args)
+	}
+	args = joinRemuxedArgs([]VideoFile{testClip("a.mp4")}, "ts", "list.txt", "out.mp4", true)
+	if slices.Contains(args, "-display_rotation:v:0") || slices.Contains(args, "-metadata:s:v:0") {
+		t.Errorf("unrotated clips got a rotation: %q", args)
+	}
+}
+
+func TestStreamIncompatibilities(t *testing.T) {
+	clip := func(name, codec, audio, timeBase, extradata string) VideoFile {
+		return testClip(name, func(v *VideoFile) {
+			v.Codec, v.AudioCodec, v.TimeBase, v.ExtradataHash = codec, audio, timeBase, extradata
+		})
+	}
+	tests := []struct {
+		name string
+		vs   []VideoFile
+		want []string // properties left after a remux
+	}{
+		{"time base differs", []VideoFile{clip("a", "h264", "aac", "1/30000", "a"), clip("b", "h264", "aac", "1/90000", "a")}, nil},
+		{"SPS differs, rewrapped to TS", []VideoFile{clip("a", "h264", "aac", "1/90000", "a"), clip("b", "h264", "aac", "1/90000", "b")}, nil},
+		{"SPS differs, LPCM forces MKV", []VideoFile{clip("a", "h264", "pcm_s16le", "1/90000", "a"), clip("b", "h264", "pcm_s16le", "1/90000", "b")}, []string{"codec configuration"}},
+		{"av1C differs in MKV", []VideoFile{clip("a", "av1", "opus", "1/1000", "a"), clip("b", "av1", "opus", "1/1000", "b")}, []string{"codec configuration"}},
+		{"audio codec differs", []VideoFile{clip("a", "h264", "aac", "1/90000", "a"), clip("b", "h264", "mp3", "1/90000", "a")}, []string{"audio codec"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range streamIncompatibilities(tt.vs, copyIncompatibilities(tt.vs)) {
+				got = append(got, r.Property)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("left %q, want %q", got, tt.want)
+			}
+			if got, want := remuxable(tt.vs), len(tt.want) == 0; got != want {
+				t.Errorf("remuxable = %v, want %v", got, want)
+			}
+		})
+	}
+}
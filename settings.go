@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settingsVersion is the current schema version of settings.json.
+const settingsVersion = 1
+
+// DefaultNamingTemplate reproduces the original "merged-video-<date>-<time>" names.
+const DefaultNamingTemplate = "merged-video-{date}-{time}"
+
+// Settings is the persisted user configuration.
+type Settings struct {
+	Version int `json:"version"`
+
+	// Encoder preference
+	UseHardwareEncoder bool            `json:"useHardwareEncoder"`
+	OutputCodec        string          `json:"outputCodec"`
+	Quality            int             `json:"quality"`
+	RateControl        RateControl     `json:"rateControl"`
+	Keyframes          KeyframeOptions `json:"keyframes"`
+	HDRMode            string          `json:"hdrMode"`
+	MergeStrategy      string          `json:"mergeStrategy"`
+	Intermediate       string          `json:"intermediate"`   // normalized clip storage: mkv, ts or nearlossless
+	RemoveOverlaps     bool            `json:"removeOverlaps"` // cut footage repeated at the joins
+	TrimBlack          bool            `json:"trimBlack"`      // cut black frames at clip edges
+	TrimSilence        bool            `json:"trimSilence"`    // cut silence at clip edges
+
+	// Output and working locations
+	DefaultOutputDir string `json:"defaultOutputDir"` // empty = last used folder
+	NamingTemplate   string `json:"namingTemplate"`   // {date}, {time}, {first}, {count}
+	TempDir          string `json:"tempDir"`          // empty = system temp dir
+	LastInputDir     string `json:"lastInputDir"`
+	LastOutputDir    string `json:"lastOutputDir"`
+
+	Workers       int    `json:"workers"` // parallel normalizations; 0 = automatic
+	DefaultPreset string `json:"defaultPreset"`
+
+	FFmpegPath  string `json:"ffmpegPath"` // empty = environment, bundled copy or PATH
+	FFprobePath string `json:"ffprobePath"`
+}
+
+// defaultSettings is used on first launch and fills fields missing from older files.
+func defaultSettings() Settings {
+	return Settings{
+		Version:        settingsVersion,
+		Quality:        DefaultQuality,
+		RateControl:    RateControl{Mode: RateQuality},
+		Keyframes:      DefaultKeyframeOptions,
+		HDRMode:        string(HDRModeTonemap),
+		MergeStrategy:  string(StrategyNormalize),
+		Intermediate:   string(IntermediateMKV),
+		NamingTemplate: DefaultNamingTemplate,
+	}
+}
+
+// validate checks the values a user can get wrong by editing the file or UI.
+func (s Settings) validate() error {
+	switch OutputCodec(s.OutputCodec) {
+	case CodecAuto, CodecH264, CodecHEVC, CodecAV1, CodecVP9:
+	default:
+		return fmt.Errorf("unknown output codec %q", s.OutputCodec)
+	}
+	if s.Quality < 0 || s.Quality > 100 {
+		return fmt.Errorf("quality must be between 0 and 100, got %d", s.Quality)
+	}
+	if err := s.RateControl.validate(); err != nil {
+		return err
+	}
+	if err := s.Keyframes.validate(); err != nil {
+		return err
+	}
+	switch HDRMode(s.HDRMode) {
+	case HDRModeTonemap, HDRModeKeep:
+	default:
+		return fmt.Errorf("unknown HDR mode %q", s.HDRMode)
+	}
+	if err := MergeStrategy(s.MergeStrategy).validate(); err != nil {
+		return err
+	}
+	if err := IntermediateFormat(s.Intermediate).validate(); err != nil {
+		return err
+	}
+	if s.Workers < 0 {
+		return fmt.Errorf("worker count must not be negative")
+	}
+	if strings.ContainsAny(s.NamingTemplate, `/\`) {
+		return fmt.Errorf("naming template must not contain path separators")
+	}
+	for _, dir := range []string{s.DefaultOutputDir, s.TempDir} {
+		if dir == "" {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s is not an existing folder", dir)
+		}
+	}
+	return nil
+}
+
+// clearMissingDirs empties folder settings whose folder no longer exists,
+// such as one on an unplugged drive, so they fall back to their defaults
+// without discarding the rest of the file.
+func (s *Settings) clearMissingDirs() {
+	for _, dir := range []*string{&s.DefaultOutputDir, &s.TempDir} {
+		if *dir == "" {
+			continue
+		}
+		if info, err := os.Stat(*dir); err != nil || !info.IsDir() {
+			log.Printf("[settings] %s is not an existing folder, using the default instead", *dir)
+			*dir = ""
+		}
+	}
+}
+
+// settingsMigrations upgrade a decoded settings file one version at a time:
+// settingsMigrations[v] turns version v into version v+1. Working on the raw
+// map lets a migration rename or reshape fields the current struct no longer has.
+var settingsMigrations = map[int]func(map[string]any){
+	// Files without a version field predate versioning and use the v1 layout.
+	0: func(m map[string]any) {},
+}
+
+// migrateSettings upgrades raw settings JSON to the current schema.
+func migrateSettings(data []byte) ([]byte, error) {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	v := 0
+	if f, ok := m["version"].(float64); ok {
+		v = int(f)
+	}
+	if v > settingsVersion {
+		return nil, fmt.Errorf("settings file version %d is newer than this app supports (%d)", v, settingsVersion)
+	}
+	for ; v < settingsVersion; v++ {
+		migrate, ok := settingsMigrations[v]
+		if !ok {
+			return nil, fmt.Errorf("no migration from settings version %d", v)
+		}
+		migrate(m)
+	}
+	m["version"] = settingsVersion
+	return json.Marshal(m)
+}
+
+// configPath returns <user config dir>/Stitcher/<name>.
+func configPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "Stitcher", name), nil
+}
+
+func settingsPath() (string, error) {
+	return configPath("settings.json")
+}
+
+// loadSettings reads and migrates the settings file. A missing file yields
+// the defaults; fields absent from the file keep their default values.
+func loadSettings() (Settings, error) {
+	s := defaultSettings()
+	path, err := settingsPath()
+	if err != nil {
+		return s, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	data, err = migrateSettings(data)
+	if err != nil {
+		return defaultSettings(), fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return defaultSettings(), fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	s.clearMissingDirs()
+	if err := s.validate(); err != nil {
+		log.Printf("[settings] ignoring invalid settings in %s: %v", path, err)
+		return defaultSettings(), nil
+	}
+	return s, nil
+}
+
+var settingsMu sync.Mutex
+
+func saveSettings(s Settings) error {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	path, err := settingsPath()
+	if err != nil {
+		return err
+	}
+	return writeJSONAtomic(path, s)
+}
+
+// writeJSONAtomic writes v as indented JSON through a temp file + rename so
+// a crash mid-write can't leave a truncated file behind.
+func writeJSONAtomic(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+	return os.Rename(tmp.Name(), path)
+}
+
+// applySettings makes s the live configuration.
+func (a *App) applySettings(s Settings) {
+	a.useHW = s.UseHardwareEncoder
+	a.codec = OutputCodec(s.OutputCodec)
+	a.quality = s.Quality
+	a.rc = s.RateControl
+	a.keyframes = s.Keyframes
+	a.hdrMode = HDRMode(s.HDRMode)
+	a.strategy = MergeStrategy(s.MergeStrategy)
+	a.intermediate = IntermediateFormat(s.Intermediate)
+	a.settings = s
+}
+
+// snapshotSettings collects the live configuration into a Settings value.
+func (a *App) snapshotSettings() Settings {
+	s := a.settings
+	s.Version = settingsVersion
+	s.UseHardwareEncoder = a.useHW
+	s.OutputCodec = string(a.codec)
+	s.Quality = a.quality
+	s.RateControl = a.rc
+	s.Keyframes = a.keyframes
+	s.HDRMode = string(a.hdrMode)
+	s.MergeStrategy = string(a.strategy)
+	s.Intermediate = string(a.intermediate)
+	return s
+}
+
+// persistSettings saves the live configuration, logging failures: a setting
+// that can't be saved still applies to this session.
+func (a *App) persistSettings() {
+	a.settings = a.snapshotSettings()
+	if err := saveSettings(a.settings); err != nil {
+		log.Printf("[settings] save failed: %v", err)
+	}
+}
+
+// GetSettings returns the current settings.
+func (a *App) GetSettings() Settings {
+	return a.snapshotSettings()
+}
+
+// UpdateSettings validates, applies and saves new settings. Changed binary
+// paths are re-resolved and validated.
+func (a *App) UpdateSettings(s Settings) (Settings, error) {
+	s.Version = settingsVersion
+	if s.NamingTemplate == "" {
+		s.NamingTemplate = DefaultNamingTemplate
+	}
+	if s.RateControl.Mode == "" {
+		s.RateControl.Mode = RateQuality
+	}
+	if s.MergeStrategy == "" {
+		s.MergeStrategy = string(StrategyNormalize)
+	}
+	if s.Intermediate == "" {
+		s.Intermediate = string(IntermediateMKV)
+	}
+	if err := s.validate(); err != nil {
+		return a.snapshotSettings(), err
+	}
+	if s.DefaultPreset != "" {
+		if _, ok := a.findPreset(s.DefaultPreset); !ok {
+			return a.snapshotSettings(), fmt.Errorf("no preset named %q", s.DefaultPreset)
+		}
+	}
+	// Binaries that don't work are rejected before anything is applied
+	if s.FFmpegPath != a.settings.FFmpegPath || s.FFprobePath != a.settings.FFprobePath {
+		if _, err := a.SetFFmpegPaths(s.FFmpegPath, s.FFprobePath); err != nil {
+			return a.snapshotSettings(), err
+		}
+	}
+	a.applySettings(s)
+	if err := saveSettings(s); err != nil {
+		return s, fmt.Errorf("settings applied but could not be saved: %w", err)
+	}
+	return a.snapshotSettings(), nil
+}
+
+// workerCount is the number of clips normalized in parallel.
+func (a *App) workerCount() int {
+	if a.settings.Workers > 0 {
+		return a.settings.Workers
+	}
+	// Each ffmpeg is multi-threaded already; a few at once keeps the disk and
+	// encoder busy without oversubscribing the CPU
+	return max(1, min(4, goruntime.NumCPU()/2))
+}
+
+// tempDir is where intermediate files go.
+func (a *App) tempDir() string {
+	return a.settings.TempDir
+}
+
+// outputName expands the naming template for a merge of the given clips,
+// unless the open project suggests an output file for them, whose name is
+// then used with ext.
+func (a *App) outputName(videoFiles []VideoFile, ext string) string {
+	if hint := a.projectOutput(videoFiles); hint != "" {
+		return strings.TrimSuffix(filepath.Base(hint), filepath.Ext(hint)) + "." + ext
+	}
+	tmpl := a.settings.NamingTemplate
+	if tmpl == "" {
+		tmpl = DefaultNamingTemplate
+	}
+	now := time.Now()
+	first := ""
+	if len(videoFiles) > 0 {
+		first = strings.TrimSuffix(videoFiles[0].FileName, filepath.Ext(videoFiles[0].FileName))
+	}
+	name := strings.NewReplacer(
+		"{date}", now.Format("20060102"),
+		"{time}", now.Format("150405"),
+		"{first}", first,
+		"{count}", fmt.Sprint(len(videoFiles)),
+	).Replace(tmpl)
+	return name + "." + ext
+}
+
+// outputExtension is the file extension offered in the save dialog: the
+// active preset's container, or one that suits the output codec.
+func (a *App) outputExtension() string {
+	if a.preset.Format != "" && a.preset.Format != "copy" {
+		return a.preset.Format
+	}
+	return defaultExtension(a.codec)
+}
+
+// outputDir is the folder the save dialog opens in for a merge of videoFiles.
+func (a *App) outputDir(videoFiles []VideoFile) string {
+	if hint := a.projectOutput(videoFiles); hint != "" {
+		return filepath.Dir(hint)
+	}
+	if a.settings.DefaultOutputDir != "" {
+		return a.settings.DefaultOutputDir
+	}
+	return a.settings.LastOutputDir
+}
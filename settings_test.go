@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		modify func(*Settings)
+		ok     bool
+	}{
+		{"defaults", func(s *Settings) {}, true},
+		{"unknown codec", func(s *Settings) { s.OutputCodec = "mpeg2" }, false},
+		{"quality out of range", func(s *Settings) { s.Quality = 101 }, false},
+		{"unknown HDR mode", func(s *Settings) { s.HDRMode = "dolby" }, false},
+		{"negative workers", func(s *Settings) { s.Workers = -1 }, false},
+		{"template with separator", func(s *Settings) { s.NamingTemplate = "a/{name}" }, false},
+		{"existing output folder", func(s *Settings) { s.DefaultOutputDir = dir }, true},
+		{"missing temp folder", func(s *Settings) { s.TempDir = filepath.Join(dir, "gone") }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := defaultSettings()
+			tt.modify(&s)
+			if err := s.validate(); (err == nil) != tt.ok {
+				t.Errorf("validate = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMigrateSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		ok   bool
+	}{
+		{"unversioned", `{"quality":70}`, true},
+		{"current", `{"version":1,"quality":70}`, true},
+		{"newer", `{"version":99}`, false},
+		{"not JSON", `{`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := migrateSettings([]byte(tt.in))
+			if (err == nil) != tt.ok {
+				t.Fatalf("migrateSettings = %v, want ok=%v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			var m map[string]any
+			if err := json.Unmarshal(out, &m); err != nil {
+				t.Fatal(err)
+			}
+			if m["version"] != float64(settingsVersion) || m["quality"] != float64(70) {
+				t.Errorf("migrated to %s", out)
+			}
+		})
+	}
+}
+
+func TestLoadSettingsMissingFolder(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("HOME", config)
+	path, err := settingsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := defaultSettings()
+	saved.Quality = 42
+	saved.DefaultOutputDir = config
+	saved.TempDir = filepath.Join(config, "unplugged")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSONAtomic(path, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := loadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Quality != 42 || s.DefaultOutputDir != config {
+		t.Errorf("valid settings were dropped: quality %d, output folder %q", s.Quality, s.DefaultOutputDir)
+	}
+	if s.TempDir != "" {
+		t.Errorf("TempDir = %q, want it cleared", s.TempDir)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// SplitRecording is a run of consecutive clips that are the parts of one
+// recording, split by the camera or recorder at a file size limit.
+type SplitRecording struct {
+	Kind      string   `json:"kind"`  // "avchd", "vob" or "ts"
+	Clips     []int    `json:"clips"` // 1-based positions in the timeline
+	FileNames []string `json:"fileNames"`
+	start     int
+	end       int // exclusive
+}
+
+var (
+	avchdNameRe = regexp.MustCompile(`^(\d{5})\.(?i:mts|m2ts)$`)
+	vobNameRe   = regexp.MustCompile(`^(?i:vts)_(\d\d)_(\d)\.(?i:vob)$`)
+)
+
+// continuityTolerance is how far (seconds) the next part may start from the
+// end of the previous one and still count as a continuation.
+const continuityTolerance = 1.0
+
+// splitKind returns the kind of split set the file could belong to, or "".
+func splitKind(v VideoFile) string {
+	name := filepath.Base(v.Path)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".vob":
+		return "vob"
+	case ".mts", ".m2ts":
+		if avchdNameRe.MatchString(name) {
+			return "avchd"
+		}
+		return "ts"
+	case ".ts":
+		return "ts"
+	}
+	return ""
+}
+
+// continues reports whether next's timestamps pick up where prev's end.
+func continues(prev, next VideoFile) bool {
+	return next.StartTime > 0 && math.Abs(next.StartTime-(prev.StartTime+prev.Duration)) <= continuityTolerance
+}
+
+// isNextPart reports whether next is the part of prev's recording that
+// follows it. DVD title sets are numbered VTS_tt_1.VOB, VTS_tt_2.VOB, ...
+// (part 0 is the menu). AVCHD spans and broadcast TS splits are recognized
+// by continuous timestamps, which separate recordings don't have; AVCHD also
+// numbers the files consecutively.
+func isNextPart(prev, next VideoFile) bool {
+	kind := splitKind(prev)
+	if kind == "" || kind != splitKind(next) || filepath.Dir(prev.Path) != filepath.Dir(next.Path) {
+		return false
+	}
+	if !remuxable([]VideoFile{prev, next}) {
+		return false
+	}
+	switch kind {
+	case "vob":
+		p := vobNameRe.FindStringSubmatch(filepath.Base(prev.Path))
+		n := vobNameRe.FindStringSubmatch(filepath.Base(next.Path))
+		if p == nil || n == nil || p[1] != n[1] {
+			return false
+		}
+		pp, _ := strconv.Atoi(p[2])
+		np, _ := strconv.Atoi(n[2])
+		return pp >= 1 && np == pp+1
+	case "avchd":
+		p, _ := strconv.Atoi(avchdNameRe.FindStringSubmatch(filepath.Base(prev.Path))[1])
+		n, _ := strconv.Atoi(avchdNameRe.FindStringSubmatch(filepath.Base(next.Path))[1])
+		return n == p+1 && continues(prev, next)
+	}
+	return continues(prev, next)
+}
+
+// detectSplitRecordings finds runs of consecutive clips that form one recording.
+func detectSplitRecordings(vs []VideoFile) []SplitRecording {
+	var sets []SplitRecording
+	for i := 0; i < len(vs); {
+		j := i + 1
+		for j < len(vs) && isNextPart(vs[j-1], vs[j]) {
+			j++
+		}
+		if j-i > 1 {
+			set := SplitRecording{Kind: splitKind(vs[i]), start: i, end: j}
+			for k := i; k < j; k++ {
+				set.Clips = append(set.Clips, k+1)
+				set.FileNames = append(set.FileNames, vs[k].FileName)
+			}
+			sets = append(sets, set)
+		}
+		i = j
+	}
+	return sets
+}
+
+// DetectSplitRecordings returns the sets of clips that are parts of one recording.
+func (a *App) DetectSplitRecordings(videoFiles []VideoFile) []SplitRecording {
+	return detectSplitRecordings(videoFiles)
+}
+
+// concatProtocolURL joins files at the byte level with ffmpeg's concat
+// protocol, which is how split MPEG-TS/PS recordings are meant to be read:
+// the parts are one stream, so timestamps and GOPs run on across the cuts.
+func concatProtocolURL(vs []VideoFile) string {
+	paths := make([]string, len(vs))
+	for i, v := range vs {
+		paths[i] = v.Path
+	}
+	return "concat:" + strings.Join(paths, "|")
+}
+
+// joinSplitRecordings replaces every split recording in the timeline by a
+// single file joined through the concat protocol, so later stages see one
+// clip without gaps at the internal cuts. The joined files live in a temp
+// dir removed by the returned cleanup.
+func (a *App) joinSplitRecordings(ctx context.Context, vs []VideoFile, sets []SplitRecording) ([]VideoFile, func(), error) {
+	tempDir, err := os.MkdirTemp(a.tempDir(), "stitcher-spans-*")
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to create temp dir for split recordings: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	var out []VideoFile
+	next := 0
+	for n, set := range sets {
+		out = append(out, vs[next:set.start]...)
+		parts := vs[set.start:set.end]
+
+		// VOB sets stay MPEG-PS, which can carry DVD LPCM audio; the rest are TS
+		ext, format := "ts", "mpegts"
+		if set.Kind == "vob" {
+			ext, format = "vob", "vob"
+		}
+		joined := filepath.Join(tempDir, fmt.Sprintf("recording-%d.%s", n, ext))
+		args := []string{"-y", "-hide_banner", "-loglevel", "error", "-fflags", "+genpts",
+			"-i", concatProtocolURL(parts),
+			"-map", "0:v:0", "-map", "0:a:0?", "-c", "copy", "-avoid_negative_ts", "make_zero", "-f", format, joined}
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("Joining split recording %s ... %s (%d parts)", parts[0].FileName, parts[len(parts)-1].FileName, len(parts)),
+		})
+		// Stay below 100% so the bar doesn't report the whole merge as done
+		if err := a.runFFmpegProgress(ctx, args, totalDuration(parts), "Joining split recording...", 0, 99); err != nil {
+			return nil, cleanup, fmt.Errorf("failed to join %s ... %s: %w", parts[0].FileName, parts[len(parts)-1].FileName, err)
+		}
+
+		v, err := a.probeVideo(joined)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		v.FileName = fmt.Sprintf("%s + %d more", parts[0].FileName, len(parts)-1)
+		v.TrimStart, v.TrimEnd = parts[0].TrimStart, parts[len(parts)-1].TrimEnd
+		out = append(out, v)
+		next = set.end
+	}
+	return append(out, vs[next:]...), cleanup, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// part returns a clip that starts start seconds into the recording.
+func part(name string, start float64, edits ...func(*VideoFile)) VideoFile {
+	return testClip(name, append([]func(*VideoFile){func(v *VideoFile) {
+		v.Container = "mpegts"
+		v.StartTime = start
+	}}, edits...)...)
+}
+
+func TestIsNextPart(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next VideoFile
+		want       bool
+	}{
+		{"avchd span", part("00001.MTS", 1), part("00002.MTS", 61), true},
+		{"avchd within tolerance", part("00001.MTS", 1), part("00002.MTS", 61.8), true},
+		{"avchd separate recordings", part("00001.MTS", 1), part("00002.MTS", 1), false},
+		{"avchd not consecutive", part("00001.MTS", 1), part("00003.MTS", 61), false},
+		{"ts split", part("rec_a.ts", 10), part("rec_b.ts", 70), true},
+		{"ts not continuous", part("rec_a.ts", 10), part("rec_b.ts", 300), false},
+		{"ts without timestamps", part("rec_a.ts", 0), part("rec_b.ts", 0), false},
+		{"vob title set", part("VTS_01_1.VOB", 0), part("VTS_01_2.VOB", 0), true},
+		{"vob menu", part("VTS_01_0.VOB", 0), part("VTS_01_1.VOB", 0), false},
+		{"vob other title", part("VTS_01_1.VOB", 0), part("VTS_02_2.VOB", 0), false},
+		{"different kinds", part("00001.MTS", 1), part("rec.ts", 61), false},
+		{"other folder", part("00001.MTS", 1), part("00002.MTS", 61, func(v *VideoFile) { v.Path = "/other/00002.MTS" }), false},
+		{"mp4", testClip("a.mp4"), testClip("b.mp4", func(v *VideoFile) { v.StartTime = 60 }), false},
+		{"different streams", part("00001.MTS", 1), part("00002.MTS", 61, func(v *VideoFile) { v.Resolution = "1440x1080" }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNextPart(tt.prev, tt.next); got != tt.want {
+				t.Errorf("isNextPart = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectSplitRecordings(t *testing.T) {
+	vs := []VideoFile{
+		testClip("intro.mp4"),
+		part("00001.MTS", 1),
+		part("00002.MTS", 61),
+		part("00003.MTS", 121),
+		part("00004.MTS", 1), // a new recording
+		part("VTS_01_1.VOB", 0),
+		part("VTS_01_2.VOB", 0),
+	}
+	sets := detectSplitRecordings(vs)
+	if len(sets) != 2 {
+		t.Fatalf("detectSplitRecordings found %d sets, want 2: %+v", len(sets), sets)
+	}
+	if s := sets[0]; s.Kind != "avchd" || !slices.Equal(s.Clips, []int{2, 3, 4}) || s.start != 1 || s.end != 4 {
+		t.Errorf("first set = %+v, want avchd clips 2-4", s)
+	}
+	if s := sets[1]; s.Kind != "vob" || !slices.Equal(s.FileNames, []string{"VTS_01_1.VOB", "VTS_01_2.VOB"}) {
+		t.Errorf("second set = %+v, want the VOB title set", s)
+	}
+	if got := detectSplitRecordings(vs[:1]); got != nil {
+		t.Errorf("detectSplitRecordings(one clip) = %+v, want nil", got)
+	}
+}
+
+func TestConcatProtocolURL(t *testing.T) {
+	vs := []VideoFile{part("00001.MTS", 1), part("00002.MTS", 61)}
+	if got, want := concatProtocolURL(vs), "concat:/clips/00001.MTS|/clips/00002.MTS"; got != want {
+		t.Errorf("concatProtocolURL = %q, want %q", got, want)
+	}
+}
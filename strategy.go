@@ -0,0 +1,35 @@
+package main
+
+import "fmt"
+
+// MergeStrategy selects how clips are merged when they can't all be stream-copied.
+type MergeStrategy string
+
+const (
+	// StrategyNormalize re-encodes every clip to a common format.
+	StrategyNormalize MergeStrategy = "normalize"
+	// StrategyConform re-encodes only the clips that differ from the
+	// majority, matching its parameters, and stream-copies the rest.
+	StrategyConform MergeStrategy = "conform"
+	// StrategyTimeline encodes all clips in one ffmpeg process through a
+	// concat filter graph, without intermediate files.
+	StrategyTimeline MergeStrategy = "timeline"
+)
+
+func (s MergeStrategy) validate() error {
+	switch s {
+	case StrategyNormalize, StrategyConform, StrategyTimeline:
+		return nil
+	}
+	return fmt.Errorf("unknown merge strategy %q", s)
+}
+
+// SetMergeStrategy selects "normalize", "conform" or "timeline".
+func (a *App) SetMergeStrategy(strategy string) error {
+	if err := MergeStrategy(strategy).validate(); err != nil {
+		return err
+	}
+	a.strategy = MergeStrategy(strategy)
+	a.persistSettings()
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// targetSizeAudioKbps is the audio bitrate reserved in target size mode.
+const targetSizeAudioKbps = 128
+
+// containerOverhead is the share of a target size kept free for muxing overhead.
+const containerOverhead = 0.02
+
+// targetVideoBitrate computes the video bitrate (kbit/s) that makes a timeline
+// of the given duration fit in sizeMB (MiB), after audio and container overhead.
+func targetVideoBitrate(sizeMB, duration float64, audio bool) (int, error) {
+	if duration <= 0 {
+		return 0, fmt.Errorf("clip durations are unknown; can't plan a target size")
+	}
+	totalKbps := sizeMB * (1 << 20) * 8 * (1 - containerOverhead) / duration / 1000
+	videoKbps := totalKbps
+	if audio {
+		videoKbps -= targetSizeAudioKbps
+	}
+	if videoKbps < 100 {
+		return 0, fmt.Errorf("%.1f MB is too small for %.0f seconds of video (would leave %.0f kb/s for video)", sizeMB, duration, videoKbps)
+	}
+	return int(videoKbps), nil
+}
+
+// twoPassEncoders can run a real two-pass encode through ffmpeg.
+var twoPassEncoders = []string{"libx264", "libx265", "libaom-av1", "libvpx-vp9"}
+
+// twoPassArgs returns the per-pass options. libx265 takes its pass settings
+// through -x265-params; callers run the result through coalesceParams.
+func twoPassArgs(encoder string, pass int, logPrefix string) []string {
+	if encoder != "libx265" {
+		return []string{"-pass", strconv.Itoa(pass), "-passlogfile", logPrefix}
+	}
+	return []string{"-x265-params", fmt.Sprintf("pass=%d:stats=%s.log", pass, logPrefix)}
+}
+
+// timelineInputs returns the input arguments for every clip, with its trims.
+func timelineInputs(vs []VideoFile) []string {
+	args := []string{}
+	for _, v := range vs {
+		args = append(args, v.inputArgs()...)
+	}
+	return args
+}
+
+// timelineGraph builds a filter_complex that conforms every input to the
+// target and joins them with the concat filter into [vout] (and [aout] when
+// any clip has audio). Clips without audio get generated silence.
+func timelineGraph(vs []VideoFile, t normTarget) string {
+	var b strings.Builder
+	for i, v := range vs {
+		fmt.Fprintf(&b, "[%d:v:0]%s[v%d];", i, t.videoFilter(i, v), i)
+		if !t.anyAudio {
+			continue
+		}
+		if v.HasAudio {
+			fmt.Fprintf(&b, "[%d:a:0]aresample=%d,aformat=sample_fmts=fltp:channel_layouts=%s[a%d];", i, t.sampleRate, t.channelLayout(), i)
+		} else {
+			fmt.Fprintf(&b, "anullsrc=r=%d:cl=%s,atrim=duration=%.3f[a%d];", t.sampleRate, t.channelLayout(), v.Duration, i)
+		}
+	}
+	for i := range vs {
+		fmt.Fprintf(&b, "[v%d]", i)
+		if t.anyAudio {
+			fmt.Fprintf(&b, "[a%d]", i)
+		}
+	}
+	if t.anyAudio {
+		fmt.Fprintf(&b, "concat=n=%d:v=1:a=1[vout][aout]", len(vs))
+	} else {
+		fmt.Fprintf(&b, "concat=n=%d:v=1:a=0[vout]", len(vs))
+	}
+	return b.String()
+}
+
+// timelineOutputArgs returns everything after the inputs for an encode of
+// the whole timeline: the graph, stream maps, encoder options and output.
+// extra holds additional output options such as two-pass settings.
+func timelineOutputArgs(vs []VideoFile, t normTarget, enc EncArgs, outputFile string, extra ...string) []string {
+	args := []string{"-filter_complex", timelineGraph(vs, t), "-map", "[vout]"}
+	if t.anyAudio {
+		args = append(args, "-map", "[aout]")
+		args = append(args, t.audioArgs()...)
+	}
+	args = append(args, enc.Codec...)
+	args = append(args, t.colorArgs(enc.Name)...)
+	args = append(args, t.keyframeArgs(enc.Name, vs)...)
+	args = coalesceParams(append(args, extra...))
+	args = append(args, "-map_metadata", "-1", "-map_chapters", "-1")
+	args = append(args, codecTagArgs(string(t.codec), outputFile)...)
+	return append(args, outputFile)
+}
+
+// encodeTimeline encodes all clips in one ffmpeg process through the concat
+// filter. Nothing is written besides the output, every input is read once,
+// and progress covers the whole timeline. A failing hardware encoder is
+// retried on the CPU.
+func (a *App) encodeTimeline(ctx context.Context, videoFiles []VideoFile, t normTarget, outputFile string) error {
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": fmt.Sprintf("Encoding %d clips in a single pass...", len(videoFiles)),
+	})
+	inputs := append([]string{"-y", "-hide_banner", "-loglevel", "error"}, timelineInputs(videoFiles)...)
+	total := totalDuration(videoFiles)
+
+	err := a.runFFmpegProgress(ctx, append(slices.Clone(inputs), timelineOutputArgs(videoFiles, t, t.enc, outputFile)...), total, "Encoding timeline...", 0, 100)
+	if err != nil && ctx.Err() == nil && isHardwareEncoder(t.enc.Name) {
+		cpu := t.cpuEncoder(a.encoders())
+		msg := fmt.Sprintf("%s failed, retrying with %s", t.enc.Name, cpu.Name)
+		log.Printf("[hw-fallback] %s: %v", msg, err)
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": msg,
+		})
+		err = a.runFFmpegProgress(ctx, append(slices.Clone(inputs), timelineOutputArgs(videoFiles, t, cpu, outputFile)...), total, "Encoding timeline...", 0, 100)
+	}
+	return err
+}
+
+// encodeTimelineToSize encodes the whole timeline in one ffmpeg process with
+// two passes so the output lands on the requested file size. Clips can't be
+// normalized independently here: the bitrate budget is shared by all of them.
+func (a *App) encodeTimelineToSize(ctx context.Context, videoFiles []VideoFile, t normTarget, outputFile string, sizeMB float64) (string, error) {
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": fmt.Sprintf("Target size %.1f MB: encoding at %d kb/s video in two passes", sizeMB, t.spec.rc.Bitrate),
+	})
+
+	passDir, err := os.MkdirTemp(a.tempDir(), "stitcher-2pass-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir for two-pass log: %w", err)
+	}
+	defer os.RemoveAll(passDir)
+	logPrefix := filepath.Join(passDir, "pass")
+
+	inputs := append([]string{"-y", "-hide_banner", "-loglevel", "error"}, timelineInputs(videoFiles)...)
+	total := totalDuration(videoFiles)
+
+	// Pass 1 only analyses video; its output is discarded. The graph must not
+	// produce audio here or concat would have an unconnected output.
+	videoOnly := t
+	videoOnly.anyAudio = false
+	pass1 := append(slices.Clone(inputs), "-filter_complex", timelineGraph(videoFiles, videoOnly), "-map", "[vout]")
+	pass1 = append(pass1, t.enc.Codec...)
+	pass1 = append(pass1, t.colorArgs(t.enc.Name)...)
+	pass1 = append(pass1, t.keyframeArgs(t.enc.Name, videoFiles)...)
+	pass1 = append(pass1, twoPassArgs(t.enc.Name, 1, logPrefix)...)
+	pass1 = append(coalesceParams(pass1), "-an", "-f", "null", os.DevNull)
+	if err := a.runFFmpegProgress(ctx, pass1, total, "Analyzing (pass 1/2)...", 0, 50); err != nil {
+		return "", err
+	}
+
+	var extra []string
+	if t.anyAudio {
+		extra = append(extra, "-b:a", fmt.Sprintf("%dk", targetSizeAudioKbps))
+	}
+	extra = append(extra, twoPassArgs(t.enc.Name, 2, logPrefix)...)
+	pass2 := append(slices.Clone(inputs), timelineOutputArgs(videoFiles, t, t.enc, outputFile, extra...)...)
+	if err := a.runFFmpegProgress(ctx, pass2, total, "Encoding (pass 2/2)...", 50, 100); err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf("Successfully merged videos to %s", outputFile)
+	info, err := os.Stat(outputFile)
+	if err != nil {
+		return result, nil
+	}
+	gotMB := float64(info.Size()) / (1 << 20)
+	report := fmt.Sprintf("Output is %.1f MB (target %.1f MB)", gotMB, sizeMB)
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": report,
+	})
+	if gotMB > sizeMB {
+		return result + ". Warning: " + report, nil
+	}
+	return result + ". " + report, nil
+}
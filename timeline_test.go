@@ -0,0 +1,129 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTargetVideoBitrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		sizeMB   float64
+		duration float64
+		audio    bool
+		want     int
+		wantErr  bool
+	}{
+		{"with audio", 100, 600, true, 1242, false},
+		{"without audio", 100, 600, false, 1370, false},
+		{"large", 4096, 3600, true, 9225, false},
+		{"too small", 1, 600, true, 0, true},
+		{"audio leaves too little", 10, 600, true, 0, true},
+		{"unknown duration", 100, 0, true, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := targetVideoBitrate(tt.sizeMB, tt.duration, tt.audio)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("targetVideoBitrate error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("targetVideoBitrate = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTwoPassArgs(t *testing.T) {
+	tests := []struct {
+		enc  string
+		pass int
+		want []string
+	}{
+		{"libx264", 1, []string{"-pass", "1", "-passlogfile", "/tmp/p"}},
+		{"libvpx-vp9", 2, []string{"-pass", "2", "-passlogfile", "/tmp/p"}},
+		{"libx265", 2, []string{"-x265-params", "pass=2:stats=/tmp/p.log"}},
+	}
+	for _, tt := range tests {
+		if got := twoPassArgs(tt.enc, tt.pass, "/tmp/p"); !slices.Equal(got, tt.want) {
+			t.Errorf("twoPassArgs(%s, %d) = %q, want %q", tt.enc, tt.pass, got, tt.want)
+		}
+	}
+}
+
+func TestTwoPassEncoderChoice(t *testing.T) {
+	have := map[string]bool{"libx264": true, "h264_nvenc": true, "libsvtav1": true, "libaom-av1": true, "av1_nvenc": true}
+	rc := RateControl{Mode: RateABR, Bitrate: 2000}
+	tests := []struct {
+		codec   OutputCodec
+		twoPass bool
+		want    string
+	}{
+		{CodecH264, false, "h264_nvenc"},
+		{CodecH264, true, "libx264"},
+		{CodecAV1, false, "av1_nvenc"},
+		{CodecAV1, true, "libaom-av1"},
+	}
+	for _, tt := range tests {
+		spec := encodeSpec{codec: tt.codec, useHW: true, rc: rc, twoPass: tt.twoPass}
+		if got := buildVideoEncoderArgs(spec, have).Name; got != tt.want {
+			t.Errorf("%s two-pass %v: encoder %s, want %s", tt.codec, tt.twoPass, got, tt.want)
+		}
+	}
+}
+
+func TestTimelineGraph(t *testing.T) {
+	target := normTarget{width: 1920, height: 1080, fps: 30, pixFmt: "yuv420p", outMatrix: "bt709",
+		hdr: hdrPlan{tonemap: make([]bool, 2)}, channels: 2, sampleRate: 48000}
+	vf := target.videoFilter(0, testClip("a"))
+	silent := testClip("b", func(v *VideoFile) { v.HasAudio = false; v.Duration = 12.5 })
+
+	withAudio := target
+	withAudio.anyAudio = true
+	tests := []struct {
+		name string
+		vs   []VideoFile
+		t    normTarget
+		want string
+	}{
+		{"video only", []VideoFile{testClip("a"), silent}, target,
+			"[0:v:0]" + vf + "[v0];[1:v:0]" + vf + "[v1];[v0][v1]concat=n=2:v=1:a=0[vout]"},
+		{"silence for a clip without audio", []VideoFile{testClip("a"), silent}, withAudio,
+			"[0:v:0]" + vf + "[v0];[0:a:0]aresample=48000,aformat=sample_fmts=fltp:channel_layouts=stereo[a0];" +
+				"[1:v:0]" + vf + "[v1];anullsrc=r=48000:cl=stereo,atrim=duration=12.500[a1];" +
+				"[v0][a0][v1][a1]concat=n=2:v=1:a=1[vout][aout]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timelineGraph(tt.vs, tt.t); got != tt.want {
+				t.Errorf("timelineGraph =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimelineOutputArgs(t *testing.T) {
+	target := normTarget{width: 1920, height: 1080, fps: 30, pixFmt: "yuv420p", outMatrix: "bt709",
+		hdr: hdrPlan{tonemap: make([]bool, 2)}, anyAudio: true, audioCodec: "aac", channels: 2, sampleRate: 48000,
+		codec: CodecHEVC, spec: encodeSpec{keyframes: DefaultKeyframeOptions}}
+	vs := []VideoFile{testClip("a"), testClip("b")}
+	enc := EncArgs{Name: "libx265", Codec: []string{"-c:v", "libx265", "-crf", "25", "-x265-params", "log-level=error"}}
+	got := timelineOutputArgs(vs, target, enc, "/out/merged.mp4", twoPassArgs("libx265", 2, "/tmp/p")...)
+
+	for _, want := range [][]string{
+		{"-map", "[vout]", "-map", "[aout]", "-c:a", "aac", "-ar", "48000", "-ac", "2"},
+		{"-force_key_frames", "60.000", "-forced-idr", "1"},
+		// the pass settings join the encoder's own params
+		{"-x265-params", "log-level=error:pass=2:stats=/tmp/p.log"},
+		{"-tag:v", "hvc1", "/out/merged.mp4"},
+	} {
+		i := slices.Index(got, want[0])
+		if i < 0 || len(got) < i+len(want) || !slices.Equal(got[i:i+len(want)], want) {
+			t.Errorf("timelineOutputArgs = %q, want %q in order", got, want)
+		}
+	}
+	if n := strings.Count(strings.Join(got, " "), "-x265-params"); n != 1 {
+		t.Errorf("timelineOutputArgs has %d -x265-params, want 1", n)
+	}
+}
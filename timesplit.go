@@ -0,0 +1,155 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// TimeSplit configures how SplitFolderByTime cuts a folder into merges.
+type TimeSplit struct {
+	Recursive bool `json:"recursive"`
+	// GapMinutes starts a new merge when a clip begins this long after the
+	// previous one ended; 0 disables gap splitting
+	GapMinutes float64 `json:"gapMinutes"`
+	// ByDay starts a new merge when the calendar day changes
+	ByDay bool `json:"byDay"`
+}
+
+func (o TimeSplit) validate() error {
+	if o.GapMinutes < 0 {
+		return fmt.Errorf("gap must not be negative")
+	}
+	if o.GapMinutes == 0 && !o.ByDay {
+		return fmt.Errorf("choose a gap, split by day, or both")
+	}
+	return nil
+}
+
+// timedClip is a clip with its recording start in local time.
+type timedClip struct {
+	v     VideoFile
+	start time.Time
+}
+
+func (c timedClip) end() time.Time {
+	return c.start.Add(time.Duration(c.v.Duration * float64(time.Second)))
+}
+
+// groupByTime sorts clips by recording time and splits them where the gap
+// or the calendar day rule says. Each camera or channel (see chapterFamily)
+// is split on its own, so a dashcam's front and rear files recorded side by
+// side end up in separate merges. Clips without a recording time can't be
+// placed and are returned separately.
+func groupByTime(vs []VideoFile, opts TimeSplit) (groups [][]timedClip, undated []VideoFile) {
+	families := map[string][]timedClip{}
+	for _, v := range vs {
+		if t, ok := clipClock(v); ok {
+			families[chapterFamily(v)] = append(families[chapterFamily(v)], timedClip{v, t})
+		} else {
+			undated = append(undated, v)
+		}
+	}
+
+	gap := time.Duration(opts.GapMinutes * float64(time.Minute))
+	for _, clips := range families {
+		slices.SortFunc(clips, func(a, b timedClip) int {
+			return cmp.Or(a.start.Compare(b.start), naturalCompare(a.v.Path, b.v.Path))
+		})
+		var group []timedClip
+		for _, c := range clips {
+			if len(group) > 0 {
+				prev := group[len(group)-1]
+				newDay := opts.ByDay && !sameDay(prev.start, c.start)
+				if newDay || (gap > 0 && c.start.Sub(prev.end()) > gap) {
+					groups = append(groups, group)
+					group = nil
+				}
+			}
+			group = append(group, c)
+		}
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	slices.SortFunc(groups, func(a, b []timedClip) int {
+		return cmp.Or(a[0].start.Compare(b[0].start), naturalCompare(a[0].v.Path, b[0].v.Path))
+	})
+	return groups, undated
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// timeRangeName names a group by when it was recorded, e.g.
+// "2024-05-01 08-12 to 10-45", spelling out the end date when it differs.
+func timeRangeName(start, end time.Time) string {
+	if sameDay(start, end) {
+		return fmt.Sprintf("%s to %s", start.Format("2006-01-02 15-04"), end.Format("15-04"))
+	}
+	return fmt.Sprintf("%s to %s", start.Format("2006-01-02 15-04"), end.Format("2006-01-02 15-04"))
+}
+
+// SplitFolderByTime lets the user pick a folder of continuous footage, such
+// as a dashcam or security camera dump, and queues one merge per stretch of
+// recording, named by its time range.
+func (a *App) SplitFolderByTime(opts TimeSplit) ([]MergeJob, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            "Select Footage Folder",
+		DefaultDirectory: a.settings.LastInputDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return []MergeJob{}, nil
+	}
+	a.settings.LastInputDir = dir
+	a.persistSettings()
+
+	paths, err := findVideoFiles(dir, opts.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	groups, undated := groupByTime(a.probeFiles(paths, nil), opts)
+	if len(undated) > 0 {
+		runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+			"message": fmt.Sprintf("%d clip(s) have no recording time and were left out", len(undated)),
+		})
+	}
+
+	var jobs []MergeJob
+	single := 0
+	for _, g := range groups {
+		if len(g) < 2 {
+			single++
+			continue
+		}
+		clips := make([]VideoFile, len(g))
+		for i, c := range g {
+			clips[i] = c.v
+		}
+		job, err := a.QueueMerge(timeRangeName(g[0].start, g[len(g)-1].end()), clips)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	msg := fmt.Sprintf("Queued %d merge(s) from %d clip(s)", len(jobs), len(paths))
+	if single > 0 {
+		msg += fmt.Sprintf("; %d stretch(es) of a single clip need no merge", single)
+	}
+	runtime.EventsEmit(a.ctx, "mergeProgress", map[string]interface{}{
+		"message": msg,
+	})
+	return jobs, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGroupByTime(t *testing.T) {
+	day := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	clip := func(name string, minutes float64) VideoFile {
+		start := day.Add(time.Duration(minutes * float64(time.Minute)))
+		return testClip(name, func(v *VideoFile) { v.CreationTime = start.Format(time.RFC3339) })
+	}
+	tests := []struct {
+		name string
+		vs   []VideoFile
+		opts TimeSplit
+		want [][]string
+	}{
+		{
+			"gap splits a run",
+			[]VideoFile{clip("0001.MP4", 0), clip("0002.MP4", 1), clip("0003.MP4", 30)},
+			TimeSplit{GapMinutes: 5},
+			[][]string{{"0001.MP4", "0002.MP4"}, {"0003.MP4"}},
+		},
+		{
+			"front and rear channels are kept apart",
+			[]VideoFile{
+				clip("20240501_080000_F.MP4", 0), clip("20240501_080000_R.MP4", 0),
+				clip("20240501_080100_F.MP4", 1), clip("20240501_080100_R.MP4", 1),
+			},
+			TimeSplit{GapMinutes: 5},
+			[][]string{
+				{"20240501_080000_F.MP4", "20240501_080100_F.MP4"},
+				{"20240501_080000_R.MP4", "20240501_080100_R.MP4"},
+			},
+		},
+		{
+			"channels split at their own gaps",
+			[]VideoFile{
+				clip("20240501_080000_F.MP4", 0), clip("20240501_080100_F.MP4", 1),
+				clip("20240501_080000_R.MP4", 0), clip("20240501_082000_R.MP4", 20),
+			},
+			TimeSplit{GapMinutes: 5},
+			[][]string{
+				{"20240501_080000_F.MP4", "20240501_080100_F.MP4"},
+				{"20240501_080000_R.MP4"},
+				{"20240501_082000_R.MP4"},
+			},
+		},
+		{
+			"new day",
+			[]VideoFile{clip("0001.MP4", 15*60), clip("0002.MP4", 16*60+1)},
+			TimeSplit{ByDay: true},
+			[][]string{{"0001.MP4"}, {"0002.MP4"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, undated := groupByTime(tt.vs, tt.opts)
+			if len(undated) > 0 {
+				t.Fatalf("%d clip(s) undated", len(undated))
+			}
+			var got [][]string
+			for _, g := range groups {
+				var names []string
+				for _, c := range g {
+					names = append(names, c.v.FileName)
+				}
+				got = append(got, names)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("groups = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupByTimeUndated(t *testing.T) {
+	_, undated := groupByTime([]VideoFile{testClip("clip.mp4")}, TimeSplit{GapMinutes: 5})
+	if len(undated) != 1 {
+		t.Errorf("undated = %d, want 1", len(undated))
+	}
+}
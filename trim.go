@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// formatSeconds renders a time for ffmpeg options and concat directives.
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
+
+// trimmedClips applies each clip's TrimStart/TrimEnd for a merge: Duration
+// becomes the length used, starting TrimStart into the file, so progress,
+// silence and keyframe placement follow the trimmed timeline. Inputs are
+// then read with inputArgs.
+func trimmedClips(vs []VideoFile) ([]VideoFile, error) {
+	out := make([]VideoFile, len(vs))
+	for i, v := range vs {
+		if v.TrimStart < 0 || v.TrimEnd < 0 {
+			return nil, fmt.Errorf("%s: trim points must not be negative", v.FileName)
+		}
+		if v.TrimStart > 0 || v.TrimEnd > 0 {
+			d := v.Duration - v.TrimStart - v.TrimEnd
+			if d <= 0 {
+				return nil, fmt.Errorf("%s: trimming %.2f s and %.2f s leaves nothing of %.2f s", v.FileName, v.TrimStart, v.TrimEnd, v.Duration)
+			}
+			v.Duration = d
+			v.cut = true
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// headTrimmed reports whether any clip starts later than its first frame.
+// Stream copy can only start at a keyframe, so such merges are re-encoded.
+func headTrimmed(vs []VideoFile) bool {
+	for _, v := range vs {
+		if v.TrimStart > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// inputArgs returns the options that open a clip, limited to its trimmed
+// range. Seeking before -i is frame accurate when decoding, and -t stops
+// reading at the out point, with or without re-encoding.
+func (v VideoFile) inputArgs() []string {
+	var args []string
+	if v.TrimStart > 0 {
+		args = append(args, "-ss", formatSeconds(v.TrimStart))
+	}
+	if v.cut {
+		args = append(args, "-t", formatSeconds(v.Duration))
+	}
+	return append(args, "-i", v.Path)
+}
+
+// writeClipConcatList writes a concat demuxer list for the clips, ending
+// trimmed clips at their out point. The concat demuxer compares out points
+// with the file's own timestamps, so they include its start time.
+func writeClipConcatList(vs []VideoFile) (string, error) {
+	f, err := os.CreateTemp("", "ffmpeg-list-*.txt")
+	if err != nil {
+		return "", err
+	}
+	for _, v := range vs {
+		entry := fmt.Sprintf("file '%s'\n", escapeFFConcatPath(v.Path))
+		if v.cut {
+			entry += fmt.Sprintf("outpoint %s\n", formatSeconds(v.StartTime+v.TrimStart+v.Duration))
+		}
+		if _, err := f.WriteString(entry); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return "", err
+		}
+	}
+	f.Close()
+	return f.Name(), nil
+}